@@ -39,7 +39,10 @@ Output will be generated in the public/ directory.`,
 			fmt.Printf("Building site from: %s\n", targetDir)
 		}
 
-		cfg := config.NewConfig(targetDir)
+		cfg, err := config.LoadConfig(targetDir)
+		if err != nil {
+			return fmt.Errorf("configuration error: %w", err)
+		}
 		if buildOutput != "" {
 			cfg.PublicDir = buildOutput
 		}
@@ -62,6 +65,10 @@ Output will be generated in the public/ directory.`,
 		}
 
 		pageProcessor := processor.NewPageProcessor(cfg, templates)
+		pageProcessor.Verbose = verbose
+		if err := pageProcessor.IndexPages(); err != nil {
+			return fmt.Errorf("page processing error: %w", err)
+		}
 
 		var processedFiles int
 		err = filepath.WalkDir(cfg.PagesDir, func(path string, info fs.DirEntry, err error) error {
@@ -85,7 +92,18 @@ Output will be generated in the public/ directory.`,
 			return fmt.Errorf("page processing error: %w", err)
 		}
 
-		err = processor.ProcessAssets(cfg.AssetsDir, cfg.PublicDir, verbose)
+		if err := pageProcessor.ProcessGeneratedPages(); err != nil {
+			return fmt.Errorf("page processing error: %w", err)
+		}
+
+		if verbose {
+			for _, stats := range pageProcessor.PartialStats() {
+				fmt.Printf("Partial cache: %s: %d hits, %d misses (%.0f%% hit rate)\n",
+					stats.Name, stats.Hits, stats.Misses, 100*stats.HitRate())
+			}
+		}
+
+		err = processor.ProcessAssets(cfg.AssetsDirs(), cfg.PublicDir, verbose)
 		if err != nil {
 			if verbose {
 				log.Printf("Asset processing warning: %v", err)
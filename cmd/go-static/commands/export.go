@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportSite   string
+	exportOutput string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the site in other formats",
+}
+
+var exportEPUBCmd = &cobra.Command{
+	Use:   "epub [section]",
+	Short: "Export a section or the whole site as an EPUB book",
+	Long: `Package the pages of a section, such as "handbook" for pages/handbook/,
+or of the whole site as an EPUB 3 book for reading offline.
+
+Each page is a chapter, with the same content as on the site, in reading
+order: a directory's index page first, then its pages and subdirectories by
+weight, then date. The book's metadata comes from the epub settings in
+config.yaml, and assets/epub.css replaces its default stylesheet.
+
+The book is written to <section>.epub, or site.epub, unless --output is set.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		section := ""
+		if len(args) > 0 {
+			section = args[0]
+		}
+
+		_, pageProcessor, err := loadSite(exportSite)
+		if err != nil {
+			return err
+		}
+
+		book, err := pageProcessor.EPUB(section)
+		if err != nil {
+			return fmt.Errorf("export error: %w", err)
+		}
+
+		output := exportOutput
+		if output == "" {
+			name := path.Base(strings.Trim(filepath.ToSlash(section), "/"))
+			if name == "" || name == "." {
+				name = "site"
+			}
+			output = name + ".epub"
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("export error: %w", err)
+		}
+		if err := book.Write(file); err != nil {
+			file.Close()
+			return fmt.Errorf("export error: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("export error: %w", err)
+		}
+
+		fmt.Printf("Exported %q to %s: %d chapters, %d images.\n", book.Title, output, len(book.Chapters), len(book.Resources))
+		return nil
+	},
+}
+
+func init() {
+	exportEPUBCmd.Flags().StringVarP(&exportSite, "site", "s", ".", "site directory")
+	exportEPUBCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "book file (default: <section>.epub)")
+	exportCmd.AddCommand(exportEPUBCmd)
+}
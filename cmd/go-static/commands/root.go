@@ -39,5 +39,7 @@ func init() {
 	rootCmd.AddCommand(buildCmd)
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(templatesCmd)
+	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(versionCmd)
 }
\ No newline at end of file
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ahoglund/go-static/pkg/config"
@@ -21,20 +22,73 @@ var (
 	serveHost string
 )
 
-func buildSite(targetDir string) error {
-	cfg := config.NewConfig(targetDir)
-	
+// siteRedirects answers requests for page aliases with a redirect, as a
+// server configured with the generated redirect files would. It is updated
+// after every successful build.
+var siteRedirects = &redirectHandler{}
+
+type redirectHandler struct {
+	mu        sync.RWMutex
+	redirects map[string]string
+	next      http.Handler
+}
+
+func (h *redirectHandler) update(redirects []processor.Redirect) {
+	byPath := make(map[string]string, len(redirects))
+	for _, redirect := range redirects {
+		byPath[redirectKey(redirect.From)] = redirect.To
+	}
+	h.mu.Lock()
+	h.redirects = byPath
+	h.mu.Unlock()
+}
+
+func (h *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	to, ok := h.redirects[redirectKey(r.URL.Path)]
+	h.mu.RUnlock()
+	if ok {
+		http.Redirect(w, r, to, http.StatusMovedPermanently)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// redirectKey lets "/old", "/old/" and "/old/index.html" match the same alias.
+func redirectKey(urlPath string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(urlPath, "index.html"), "/")
+}
+
+// loadSite reads the configuration and templates of a site and indexes its
+// pages.
+func loadSite(targetDir string) (*config.Config, *processor.PageProcessor, error) {
+	cfg, err := config.LoadConfig(targetDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("configuration error: %w", err)
+	}
+
 	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("configuration error: %w", err)
+		return nil, nil, fmt.Errorf("configuration error: %w", err)
 	}
 
 	templateLoader := template.NewTemplateLoader(cfg)
 	templates, err := templateLoader.LoadTemplates()
 	if err != nil {
-		return fmt.Errorf("template loading error: %w", err)
+		return nil, nil, fmt.Errorf("template loading error: %w", err)
 	}
 
 	pageProcessor := processor.NewPageProcessor(cfg, templates)
+	if err := pageProcessor.IndexPages(); err != nil {
+		return nil, nil, fmt.Errorf("page processing error: %w", err)
+	}
+	return cfg, pageProcessor, nil
+}
+
+func buildSite(targetDir string) error {
+	cfg, pageProcessor, err := loadSite(targetDir)
+	if err != nil {
+		return err
+	}
 
 	err = filepath.WalkDir(cfg.PagesDir, func(path string, info os.DirEntry, err error) error {
 		if err != nil {
@@ -61,8 +115,18 @@ func buildSite(targetDir string) error {
 		return fmt.Errorf("page processing error: %w", err)
 	}
 
+	if err := pageProcessor.ProcessGeneratedPages(); err != nil {
+		return fmt.Errorf("page processing error: %w", err)
+	}
+
+	redirects, err := pageProcessor.Redirects()
+	if err != nil {
+		return fmt.Errorf("page processing error: %w", err)
+	}
+	siteRedirects.update(redirects)
+
 	fmt.Printf("  Processing assets from %s to %s\n", cfg.AssetsDir, cfg.PublicDir)
-	err = processor.ProcessAssets(cfg.AssetsDir, cfg.PublicDir, false)
+	err = processor.ProcessAssets(cfg.AssetsDirs(), cfg.PublicDir, false)
 	if err != nil {
 		log.Printf("Asset processing warning: %v", err)
 	}
@@ -108,9 +172,13 @@ func watchAndRebuild(targetDir string) {
 	}
 	defer watcher.Close()
 
-	cfg := config.NewConfig(targetDir)
+	cfg, err := config.LoadConfig(targetDir)
+	if err != nil {
+		cfg = config.NewConfig(targetDir)
+	}
 	
-	watchDirs := []string{cfg.PagesDir, cfg.TemplateDir, cfg.AssetsDir}
+	watchDirs := []string{cfg.PagesDir, cfg.TemplateDir, cfg.AssetsDir, cfg.PartialsDir, cfg.I18nDir, cfg.DataDir}
+	watchDirs = append(watchDirs, cfg.ThemeDirs()...)
 	for _, dir := range watchDirs {
 		if _, err := os.Stat(dir); err == nil {
 			filepath.WalkDir(dir, func(path string, info os.DirEntry, err error) error {
@@ -180,6 +248,8 @@ The server watches for changes in:
 - pages/     - Source files (.md, .html, .tmpl)
 - templates/ - Template files
 - assets/    - Static assets
+- data/      - Data files
+- themes/    - Configured themes
 
 If the public/ directory doesn't exist, an initial build will be attempted.`,
 	Args: cobra.MaximumNArgs(1),
@@ -189,6 +259,11 @@ If the public/ directory doesn't exist, an initial build will be attempted.`,
 			targetDir = args[0]
 		}
 
+		cfg, err := config.LoadConfig(targetDir)
+		if err != nil {
+			return fmt.Errorf("configuration error: %w", err)
+		}
+
 		publicDir := filepath.Join(targetDir, "public")
 		
 		// Try to build the site if public directory doesn't exist
@@ -197,20 +272,27 @@ If the public/ directory doesn't exist, an initial build will be attempted.`,
 			if err := buildSite(targetDir); err != nil {
 				return fmt.Errorf("initial build failed: %w", err)
 			}
+		} else if _, pageProcessor, err := loadSite(targetDir); err == nil {
+			// Redirect aliases of the existing build right away
+			if redirects, err := pageProcessor.Redirects(); err == nil {
+				siteRedirects.update(redirects)
+			}
 		}
 
 		addr := serveHost + ":" + servePort
 		
 		fmt.Printf("Serving site from: %s\n", publicDir)
-		fmt.Printf("Server running at: http://%s\n", addr)
+		fmt.Printf("Server running at: http://%s%s/\n", addr, cfg.BasePath())
 		fmt.Println("Watching for file changes...")
 		fmt.Println("Press Ctrl+C to stop")
 
 		// Start file watcher in a goroutine
 		go watchAndRebuild(targetDir)
 
+		// Serve under the base path so permalinks work as they will when deployed
 		fs := http.FileServer(http.Dir(publicDir))
-		http.Handle("/", fs)
+		siteRedirects.next = http.StripPrefix(cfg.BasePath(), fs)
+		http.Handle(cfg.BasePath()+"/", siteRedirects)
 
 		if err := http.ListenAndServe(addr, nil); err != nil {
 			return fmt.Errorf("server error: %w", err)
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ahoglund/go-static/pkg/processor"
+	"github.com/spf13/cobra"
+)
+
+// maxListedPages is how many pages are listed per template without --verbose.
+const maxListedPages = 3
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates [directory]",
+	Short: "List the site's templates and where they are used",
+	Long: `List every template defined in templates/ and the themes' templates:
+each file's own template and its {{define}} and {{block}} names, with
+their source files and the pages that use them.
+
+It also reports:
+- duplicate definitions, where a later {{define}} silently replaces an
+  earlier one with the same name
+- templates that no page uses, directly or through other templates
+
+Blocks defined in layout files such as _default/single.tmpl only apply to
+that layout, so they are not duplicates of each other.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targetDir := "."
+		if len(args) > 0 {
+			targetDir = args[0]
+		}
+
+		_, pageProcessor, err := loadSite(targetDir)
+		if err != nil {
+			return err
+		}
+
+		usages, err := pageProcessor.InspectTemplates()
+		if err != nil {
+			return fmt.Errorf("template inspection error: %w", err)
+		}
+
+		relative := func(file string) string {
+			if rel, err := filepath.Rel(targetDir, file); err == nil {
+				return rel
+			}
+			return file
+		}
+
+		fmt.Println("Templates:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tFILE\tUSED BY")
+		for _, usage := range usages {
+			if usage.IsFile && usage.Empty && usage.Layout == "" {
+				// A file that only holds definitions
+				continue
+			}
+			name := usage.Name
+			if !usage.IsFile && usage.Layout != "" {
+				name += " (block)"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", name, relative(usage.File.Path), usedBy(usage))
+		}
+		w.Flush()
+
+		var duplicates, unused []string
+		for _, usage := range usages {
+			if usage.OverriddenBy != nil {
+				duplicates = append(duplicates, fmt.Sprintf("%q in %s is overridden by %s",
+					usage.Name, relative(usage.File.Path), relative(usage.OverriddenBy.File.Path)))
+			}
+			switch {
+			case usage.Used, usage.OverriddenBy != nil:
+			case usage.IsFile && usage.Empty && usage.Layout == "":
+			case !usage.IsFile && usage.Layout != "":
+				// Reported with its layout file
+			case usage.IsFile:
+				unused = append(unused, relative(usage.File.Path))
+			default:
+				unused = append(unused, fmt.Sprintf("%q in %s", usage.Name, relative(usage.File.Path)))
+			}
+		}
+
+		if len(duplicates) > 0 {
+			fmt.Println("\nDuplicate definitions:")
+			for _, duplicate := range duplicates {
+				fmt.Printf("  %s\n", duplicate)
+			}
+		}
+		if len(unused) > 0 {
+			fmt.Println("\nUnused templates:")
+			for _, name := range unused {
+				fmt.Printf("  %s\n", name)
+			}
+		}
+		return nil
+	},
+}
+
+// usedBy describes the pages rendered with a template, listing the first
+// few unless verbose.
+func usedBy(usage *processor.TemplateUsage) string {
+	if len(usage.Pages) == 0 {
+		if usage.Used {
+			return "(other templates)"
+		}
+		return "-"
+	}
+
+	var paths []string
+	for i, page := range usage.Pages {
+		if i == maxListedPages && !verbose {
+			paths = append(paths, fmt.Sprintf("and %d more", len(usage.Pages)-i))
+			break
+		}
+		paths = append(paths, page.Path)
+	}
+	return fmt.Sprintf("%d page(s): %s", len(usage.Pages), strings.Join(paths, ", "))
+}
@@ -3,6 +3,7 @@ module github.com/ahoglund/go-static
 go 1.19
 
 require (
+	github.com/alecthomas/chroma/v2 v2.14.0
 	github.com/evanw/esbuild v0.25.9
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/gomarkdown/markdown v0.0.0-20221013030248-663e2500819c
@@ -11,6 +12,7 @@ require (
 )
 
 require (
+	github.com/dlclark/regexp2 v1.11.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	golang.org/x/sys v0.13.0 // indirect
@@ -1,26 +1,371 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// ConfigFile is the optional site configuration file, relative to the site root.
+const ConfigFile = "config.yaml"
+
 type Config struct {
-	TemplateDir string
-	PagesDir    string
-	PublicDir   string
-	AssetsDir   string
+	RootDir     string `yaml:"-"`
+	TemplateDir string `yaml:"-"`
+	PagesDir    string `yaml:"-"`
+	PublicDir   string `yaml:"-"`
+	AssetsDir   string `yaml:"-"`
+	PartialsDir string `yaml:"-"`
+	I18nDir     string `yaml:"-"`
+	DataDir     string `yaml:"-"`
+	ThemesDir   string `yaml:"-"`
+	GeminiDir   string `yaml:"-"`
+
+	// BaseURL is where the site is published, e.g. "https://example.com/docs/".
+	// Its path is prepended to every permalink.
+	BaseURL string `yaml:"baseURL"`
+
+	// PrettyURLs writes about.md to about/index.html and links to it as /about/.
+	PrettyURLs bool `yaml:"prettyURLs"`
+
+	// Theme names the directories under ThemesDir that the site builds on,
+	// highest priority first. The site's own templates, assets, data and
+	// i18n strings override the themes'.
+	Theme StringList `yaml:"theme"`
+
+	// StrictTemplates makes referencing a missing map key, such as an unset
+	// frontmatter field, a template error instead of "<no value>".
+	StrictTemplates bool `yaml:"strictTemplates"`
+
+	WikiLinks WikiLinksConfig `yaml:"wikiLinks"`
+
+	// Converters maps page file extensions, such as ".adoc", to external
+	// commands that turn the page body into HTML.
+	Converters map[string]CommandConfig `yaml:"converters"`
+
+	// Filters are external commands that post-process rendered pages, in
+	// order.
+	Filters []FilterConfig `yaml:"filters"`
+
+	// Menus are named navigation menus, such as "main", extended by pages
+	// with menu frontmatter.
+	Menus map[string][]MenuEntryConfig `yaml:"menus"`
+
+	Related RelatedConfig `yaml:"related"`
+
+	// Languages makes the site multilingual, keyed by language code such as
+	// "de". Without languages, the site has a single unnamed language.
+	Languages map[string]LanguageConfig `yaml:"languages"`
+
+	// DefaultLanguage is the code of the language of pages without a
+	// language suffix or directory. It defaults to the language with the
+	// lowest weight. On a site without languages, it only names the file of
+	// its i18n strings.
+	DefaultLanguage string `yaml:"defaultLanguage"`
+
+	// DefaultLanguageInSubdir writes the default language's pages under
+	// "/<code>" like the other languages, with a redirect from the site
+	// root, instead of at the site root.
+	DefaultLanguageInSubdir bool `yaml:"defaultLanguageInSubdir"`
+
+	// Redirects selects files listing the redirects from page aliases for
+	// web servers, in addition to the generated redirect pages.
+	Redirects RedirectsConfig `yaml:"redirects"`
+
+	// GitInfo reads the last commit of every page from the local git
+	// repository.
+	GitInfo GitInfoConfig `yaml:"gitInfo"`
+
+	// EditURL is prepended to the source path of a page, relative to the
+	// site root, for "edit this page" links, e.g.
+	// "https://github.com/user/site/edit/main/".
+	EditURL string `yaml:"editURL"`
+
+	// Outputs lists the formats pages are rendered to by page kind: "home",
+	// "section", "taxonomy" or "page", e.g. {page: [html, json]}. Kinds
+	// that aren't listed are rendered to HTML only. Pages can override
+	// their kind's formats with outputs frontmatter.
+	Outputs map[string][]string `yaml:"outputs"`
+
+	// LLMs writes llms.txt and llms-full.txt for language models and other
+	// machine readers.
+	LLMs LLMsConfig `yaml:"llms"`
+
+	// Gemini also publishes the Markdown pages as a Gemini capsule in
+	// GeminiDir.
+	Gemini GeminiConfig `yaml:"gemini"`
+
+	// EPUB is the metadata of books made with go-static export epub.
+	EPUB EPUBConfig `yaml:"epub"`
+}
+
+type EPUBConfig struct {
+	// Title defaults to the title of the exported section's index page,
+	// or of the home page.
+	Title     string `yaml:"title"`
+	Author    string `yaml:"author"`
+	Publisher string `yaml:"publisher"`
+	Rights    string `yaml:"rights"`
+	// Identifier is the book's unique ID, such as an ISBN URN. It defaults
+	// to a UUID derived from baseURL and the title.
+	Identifier string `yaml:"identifier"`
+}
+
+type GeminiConfig struct {
+	// Enabled converts every Markdown page to gemtext when building.
+	Enabled bool `yaml:"enabled"`
+	// Title heads the capsule's index and gemlog. It defaults to the title
+	// of the home page.
+	Title string `yaml:"title"`
+}
+
+type LLMsConfig struct {
+	// Enabled writes llms.txt, llms-full.txt and a Markdown copy of every
+	// Markdown page next to its HTML.
+	Enabled bool `yaml:"enabled"`
+	// Summary describes the site under its title in llms.txt.
+	Summary string `yaml:"summary"`
+	// Pages are the source paths, relative to PagesDir, that llms.txt links
+	// to, before the pages with llms frontmatter. Without either, it links
+	// to every Markdown page.
+	Pages []string `yaml:"pages"`
+}
+
+// OutputFormat is a file format pages can be rendered to.
+type OutputFormat struct {
+	Name      string
+	MediaType string
+	// Extension is the extension of the output files, and the one before
+	// .tmpl in the format's layouts, e.g. ".json" for single.json.tmpl.
+	Extension string
+}
+
+// OutputFormats are the formats Outputs can name. HTML layouts keep the
+// plain .tmpl extension.
+var OutputFormats = map[string]OutputFormat{
+	"html": {Name: "html", MediaType: "text/html", Extension: ".html"},
+	"json": {Name: "json", MediaType: "application/json", Extension: ".json"},
+	"txt":  {Name: "txt", MediaType: "text/plain", Extension: ".txt"},
+	"md":   {Name: "md", MediaType: "text/markdown", Extension: ".md"},
+	"ics":  {Name: "ics", MediaType: "text/calendar", Extension: ".ics"},
+}
+
+// OutputKinds are the page kinds Outputs is keyed by.
+var OutputKinds = []string{"home", "section", "taxonomy", "page"}
+
+type GitInfoConfig struct {
+	// Enabled runs git log when building. It is off by default because it
+	// needs the site to be in a git repository with its history.
+	Enabled bool `yaml:"enabled"`
+}
+
+// RedirectsConfig enables the redirect files written to PublicDir.
+type RedirectsConfig struct {
+	// Netlify writes a _redirects file in the format of Netlify and
+	// Cloudflare Pages.
+	Netlify bool `yaml:"netlify"`
+	// Nginx writes redirects.map, entries for an nginx map block.
+	Nginx bool `yaml:"nginx"`
+}
+
+type LanguageConfig struct {
+	// Name is shown in language switchers, e.g. "Deutsch".
+	Name string `yaml:"name"`
+	// Weight orders languages; lower comes first.
+	Weight int `yaml:"weight"`
+	// BasePath is the URL path and output subdirectory of the language's
+	// pages. It defaults to "/<code>", or to the site root for the default
+	// language unless DefaultLanguageInSubdir is set.
+	BasePath *string `yaml:"basePath"`
+}
+
+// RelatedConfig controls how .Related ranks pages.
+type RelatedConfig struct {
+	// Keys maps frontmatter keys to the weight of each value two pages
+	// share. It defaults to DefaultRelatedKeys.
+	Keys map[string]int `yaml:"keys"`
+	// Limit is the maximum number of related pages.
+	Limit int `yaml:"limit"`
+}
+
+// DefaultRelatedKeys are used when related.keys isn't configured.
+var DefaultRelatedKeys = map[string]int{"tags": 1, "categories": 1}
+
+// MenuEntryConfig is a menu entry linking to a page or to any URL.
+type MenuEntryConfig struct {
+	Name string `yaml:"name"`
+	// Page is a source path relative to PagesDir, e.g. "blog/index.md".
+	Page string `yaml:"page"`
+	// URL is used for entries that don't link to a page.
+	URL    string `yaml:"url"`
+	Weight int    `yaml:"weight"`
+	// Identifier names the entry for the Parent of other entries. It
+	// defaults to Name.
+	Identifier string `yaml:"identifier"`
+	Parent     string `yaml:"parent"`
+}
+
+// DefaultCommandTimeout limits external converters and filters that don't
+// set a timeout of their own.
+const DefaultCommandTimeout = 30 * time.Second
+
+// CommandConfig is an external command run for a page. The command gets the
+// page body on stdin and its frontmatter as JSON in GO_STATIC_FRONTMATTER,
+// and writes HTML to stdout.
+type CommandConfig struct {
+	// Command is the program and its arguments, run from the site root.
+	Command []string `yaml:"command"`
+	// Timeout defaults to DefaultCommandTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Filter stages.
+const (
+	// FilterContent filters the converted page body before the layout is
+	// applied.
+	FilterContent = "content"
+	// FilterPage filters the complete page after the layout is applied.
+	FilterPage = "page"
+)
+
+type FilterConfig struct {
+	CommandConfig `yaml:",inline"`
+	// Stage is FilterContent (the default) or FilterPage.
+	Stage string `yaml:"stage"`
+	// Extensions limits the filter to pages with these source extensions.
+	// An empty list matches every page.
+	Extensions []string `yaml:"extensions"`
+}
+
+// How unresolved [[wiki links]] are handled.
+const (
+	WikiLinksWarn  = "warn"
+	WikiLinksError = "error"
+	WikiLinksStub  = "stub"
+)
+
+type WikiLinksConfig struct {
+	// Unresolved is one of WikiLinksWarn (the default), WikiLinksError or
+	// WikiLinksStub, which generates a placeholder page for the target.
+	Unresolved string `yaml:"unresolved"`
 }
 
 func NewConfig(targetDir string) *Config {
 	targetDir = strings.TrimSuffix(targetDir, "/")
-	
+
 	return &Config{
+		RootDir:     targetDir,
 		TemplateDir: targetDir + "/templates",
 		PagesDir:    targetDir + "/pages",
 		PublicDir:   targetDir + "/public",
 		AssetsDir:   targetDir + "/assets",
+		PartialsDir: targetDir + "/partials",
+		I18nDir:     targetDir + "/i18n",
+		DataDir:     targetDir + "/data",
+		ThemesDir:   targetDir + "/themes",
+		GeminiDir:   targetDir + "/public-gemini",
+		WikiLinks: WikiLinksConfig{
+			Unresolved: WikiLinksWarn,
+		},
+		Related: RelatedConfig{
+			Limit: 5,
+		},
+	}
+}
+
+// LoadConfig returns the default configuration for targetDir, overridden by
+// the site's config.yaml when one exists.
+func LoadConfig(targetDir string) (*Config, error) {
+	cfg := NewConfig(targetDir)
+
+	configPath := filepath.Join(targetDir, ConfigFile)
+	content, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing YAML in %s: %w", configPath, err)
+	}
+	for i := range cfg.Filters {
+		if cfg.Filters[i].Stage == "" {
+			cfg.Filters[i].Stage = FilterContent
+		}
+	}
+
+	return cfg, nil
+}
+
+// StringList is a list of strings that may also be written as a single
+// string in YAML.
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
 	}
+	*l = list
+	return nil
+}
+
+// ThemeDirs returns the directories of the configured themes, highest
+// priority first.
+func (c *Config) ThemeDirs() []string {
+	dirs := make([]string, 0, len(c.Theme))
+	for _, theme := range c.Theme {
+		dirs = append(dirs, filepath.Join(c.ThemesDir, theme))
+	}
+	return dirs
+}
+
+// TemplateDirs, AssetsDirs, DataDirs and I18nDirs return the site's
+// directory followed by the same directory of each theme, highest priority
+// first.
+func (c *Config) TemplateDirs() []string { return c.layered(c.TemplateDir, "templates") }
+func (c *Config) AssetsDirs() []string   { return c.layered(c.AssetsDir, "assets") }
+func (c *Config) DataDirs() []string     { return c.layered(c.DataDir, "data") }
+func (c *Config) I18nDirs() []string     { return c.layered(c.I18nDir, "i18n") }
+
+func (c *Config) layered(siteDir, name string) []string {
+	dirs := []string{siteDir}
+	for _, dir := range c.ThemeDirs() {
+		dirs = append(dirs, filepath.Join(dir, name))
+	}
+	return dirs
+}
+
+// BasePath returns the path component of BaseURL without a trailing slash,
+// or "" when the site is served from the root.
+func (c *Config) BasePath() string {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// AbsURL turns a site-relative permalink into an absolute URL using the
+// scheme and host of BaseURL. Without a host the permalink is returned as is.
+func (c *Config) AbsURL(permalink string) string {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || u.Host == "" {
+		return permalink
+	}
+	return u.Scheme + "://" + u.Host + permalink
 }
 
 func (c *Config) Validate() error {
@@ -33,5 +378,64 @@ func (c *Config) Validate() error {
 	if c.PublicDir == "" {
 		return fmt.Errorf("public directory cannot be empty")
 	}
+	if _, err := url.Parse(c.BaseURL); err != nil {
+		return fmt.Errorf("invalid baseURL %q: %w", c.BaseURL, err)
+	}
+	for i, dir := range c.ThemeDirs() {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("theme %q not found in %s", c.Theme[i], c.ThemesDir)
+		}
+	}
+	switch c.WikiLinks.Unresolved {
+	case WikiLinksWarn, WikiLinksError, WikiLinksStub:
+	default:
+		return fmt.Errorf("wikiLinks.unresolved must be %q, %q or %q, not %q",
+			WikiLinksWarn, WikiLinksError, WikiLinksStub, c.WikiLinks.Unresolved)
+	}
+	for ext, converter := range c.Converters {
+		if len(converter.Command) == 0 {
+			return fmt.Errorf("converters.%s: command cannot be empty", ext)
+		}
+	}
+	for kind, formats := range c.Outputs {
+		known := false
+		for _, k := range OutputKinds {
+			known = known || k == kind
+		}
+		if !known {
+			return fmt.Errorf("outputs.%s: unknown page kind, must be one of %s", kind, strings.Join(OutputKinds, ", "))
+		}
+		if len(formats) == 0 {
+			return fmt.Errorf("outputs.%s: list at least one format", kind)
+		}
+		for _, format := range formats {
+			if _, ok := OutputFormats[format]; !ok {
+				return fmt.Errorf("outputs.%s: unknown output format %q", kind, format)
+			}
+		}
+	}
+	if c.DefaultLanguage != "" && len(c.Languages) > 0 {
+		if _, ok := c.Languages[c.DefaultLanguage]; !ok {
+			return fmt.Errorf("defaultLanguage %q is not one of the languages", c.DefaultLanguage)
+		}
+	}
+	for menu, entries := range c.Menus {
+		for i, entry := range entries {
+			if entry.Name == "" && entry.Page == "" {
+				return fmt.Errorf("menus.%s[%d]: name cannot be empty", menu, i)
+			}
+			if (entry.Page == "") == (entry.URL == "") {
+				return fmt.Errorf("menus.%s[%d]: exactly one of page and url must be set", menu, i)
+			}
+		}
+	}
+	for i, filter := range c.Filters {
+		if len(filter.Command) == 0 {
+			return fmt.Errorf("filters[%d]: command cannot be empty", i)
+		}
+		if filter.Stage != FilterContent && filter.Stage != FilterPage {
+			return fmt.Errorf("filters[%d]: stage must be %q or %q, not %q", i, FilterContent, FilterPage, filter.Stage)
+		}
+	}
 	return nil
-}
\ No newline at end of file
+}
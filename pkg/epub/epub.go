@@ -0,0 +1,247 @@
+// Package epub writes EPUB 3 books: a package document with the book's
+// metadata, a navigation document, XHTML chapters, a stylesheet and images,
+// zipped in the layout e-readers expect.
+package epub
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultStylesheet styles chapters when the book has no stylesheet of its
+// own.
+const DefaultStylesheet = `body { font-family: serif; line-height: 1.5; margin: 0 1em; }
+h1, h2, h3, h4, h5, h6 { font-family: sans-serif; line-height: 1.2; page-break-after: avoid; }
+img { max-width: 100%; height: auto; }
+pre { white-space: pre-wrap; font-size: 0.85em; background: #f4f4f4; padding: 0.5em; }
+code { font-family: monospace; }
+blockquote { margin: 1em 1.5em; font-style: italic; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #999; padding: 0.25em 0.5em; }
+nav ol { list-style: none; }
+`
+
+// Book is an EPUB 3 publication.
+type Book struct {
+	// Identifier is the book's unique ID, such as a URN. NewIdentifier
+	// derives one from a name.
+	Identifier  string
+	Title       string
+	Language    string
+	Creator     string
+	Publisher   string
+	Description string
+	Rights      string
+	// Modified is when the book was last changed.
+	Modified time.Time
+	// Stylesheet is the CSS linked from every chapter.
+	Stylesheet string
+	Chapters   []Chapter
+	Resources  []Resource
+}
+
+// Chapter is a content document of the book, in reading order.
+type Chapter struct {
+	Title string
+	// Level nests the chapter in the table of contents, from 0.
+	Level int
+	// Body is the XHTML content of the chapter's body element.
+	Body string
+}
+
+// Resource is a file, such as an image, that chapters refer to.
+type Resource struct {
+	// Name is the path of the file relative to the book's content
+	// directory, e.g. "images/diagram.png".
+	Name      string
+	MediaType string
+	Data      []byte
+}
+
+// ChapterName returns the path of the i-th chapter, from 0, relative to the
+// book's content directory.
+func ChapterName(i int) string {
+	return fmt.Sprintf("text/chapter-%03d.xhtml", i+1)
+}
+
+// NewIdentifier returns a stable urn:uuid identifier for a name, such as
+// the site's URL, so rebuilding a book doesn't make it a different one.
+func NewIdentifier(name string) string {
+	sum := sha1.Sum([]byte(name))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// contentDir holds everything but the mimetype file and META-INF.
+const contentDir = "EPUB"
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="EPUB/package.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// Write writes the book as an EPUB file: the uncompressed mimetype entry
+// first, as the format requires, then the container, package and
+// navigation documents, the stylesheet, chapters and resources.
+func (b *Book) Write(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	mimetype, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	stylesheet := b.Stylesheet
+	if stylesheet == "" {
+		stylesheet = DefaultStylesheet
+	}
+	type entry struct {
+		name string
+		data []byte
+	}
+	files := []entry{
+		{"META-INF/container.xml", []byte(containerXML)},
+		{contentDir + "/package.opf", []byte(b.packageDocument())},
+		{contentDir + "/nav.xhtml", []byte(b.navDocument())},
+		{contentDir + "/style.css", []byte(stylesheet)},
+	}
+	for i, chapter := range b.Chapters {
+		files = append(files, entry{contentDir + "/" + ChapterName(i), []byte(b.chapterDocument(chapter))})
+	}
+	for _, resource := range b.Resources {
+		files = append(files, entry{contentDir + "/" + resource.Name, resource.Data})
+	}
+
+	for _, file := range files {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: b.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(file.data); err != nil {
+			return fmt.Errorf("error writing %s: %w", file.name, err)
+		}
+	}
+	return z.Close()
+}
+
+func (b *Book) packageDocument() string {
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&s, `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="%s">`+"\n", escape(b.Language, true))
+	s.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&s, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", escape(b.Identifier, false))
+	fmt.Fprintf(&s, "    <dc:title>%s</dc:title>\n", escape(b.Title, false))
+	fmt.Fprintf(&s, "    <dc:language>%s</dc:language>\n", escape(b.Language, false))
+	for _, element := range []struct{ name, value string }{
+		{"creator", b.Creator},
+		{"publisher", b.Publisher},
+		{"description", b.Description},
+		{"rights", b.Rights},
+	} {
+		if element.value != "" {
+			fmt.Fprintf(&s, "    <dc:%s>%s</dc:%s>\n", element.name, escape(element.value, false), element.name)
+		}
+	}
+	fmt.Fprintf(&s, "    <meta property=\"dcterms:modified\">%s</meta>\n", b.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	s.WriteString("  </metadata>\n  <manifest>\n")
+	s.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	s.WriteString(`    <item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	for i, chapter := range b.Chapters {
+		var properties []string
+		if strings.Contains(chapter.Body, "<math") {
+			properties = append(properties, "mathml")
+		}
+		if strings.Contains(chapter.Body, "<svg") {
+			properties = append(properties, "svg")
+		}
+		attr := ""
+		if len(properties) > 0 {
+			attr = fmt.Sprintf(` properties="%s"`, strings.Join(properties, " "))
+		}
+		fmt.Fprintf(&s, "    <item id=\"chapter-%03d\" href=\"%s\" media-type=\"application/xhtml+xml\"%s/>\n", i+1, ChapterName(i), attr)
+	}
+	for i, resource := range b.Resources {
+		fmt.Fprintf(&s, "    <item id=\"resource-%03d\" href=\"%s\" media-type=\"%s\"/>\n", i+1, escape(resource.Name, true), escape(resource.MediaType, true))
+	}
+	s.WriteString("  </manifest>\n  <spine>\n")
+	for i := range b.Chapters {
+		fmt.Fprintf(&s, "    <itemref idref=\"chapter-%03d\"/>\n", i+1)
+	}
+	s.WriteString("  </spine>\n</package>\n")
+	return s.String()
+}
+
+// navDocument is the table of contents, with chapters nested by level.
+func (b *Book) navDocument() string {
+	var s strings.Builder
+	s.WriteString(xhtmlHeader(b.Language, b.Title, "style.css"))
+	s.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n")
+	fmt.Fprintf(&s, "<h1>%s</h1>\n", escape(b.Title, false))
+
+	depth := -1
+	for i, chapter := range b.Chapters {
+		level := chapter.Level
+		if level > depth+1 {
+			level = depth + 1
+		}
+		if level < 0 {
+			level = 0
+		}
+		switch {
+		case level > depth:
+			s.WriteString("<ol>\n")
+		case level == depth:
+			s.WriteString("</li>\n")
+		default:
+			for ; depth > level; depth-- {
+				s.WriteString("</li>\n</ol>\n")
+			}
+			s.WriteString("</li>\n")
+		}
+		depth = level
+		fmt.Fprintf(&s, "<li><a href=\"%s\">%s</a>", ChapterName(i), escape(chapter.Title, false))
+	}
+	for ; depth >= 0; depth-- {
+		s.WriteString("</li>\n</ol>\n")
+	}
+	s.WriteString("</nav>\n</body>\n</html>\n")
+	return s.String()
+}
+
+func (b *Book) chapterDocument(chapter Chapter) string {
+	stylesheet := path.Join(strings.Repeat("../", strings.Count(ChapterName(0), "/")), "style.css")
+	var s strings.Builder
+	s.WriteString(xhtmlHeader(b.Language, chapter.Title, stylesheet))
+	s.WriteString("<section epub:type=\"chapter\">\n")
+	if !strings.HasPrefix(strings.TrimSpace(chapter.Body), "<h1") {
+		fmt.Fprintf(&s, "<h1>%s</h1>\n", escape(chapter.Title, false))
+	}
+	s.WriteString(chapter.Body)
+	s.WriteString("\n</section>\n</body>\n</html>\n")
+	return s.String()
+}
+
+func xhtmlHeader(language, title, stylesheet string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%[1]s" lang="%[1]s">
+<head>
+<meta charset="UTF-8"/>
+<title>%[2]s</title>
+<link rel="stylesheet" type="text/css" href="%[3]s"/>
+</head>
+<body>
+`, escape(language, true), escape(title, false), stylesheet)
+}
@@ -0,0 +1,152 @@
+package epub
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// voidElements have no end tag in HTML and are self-closing in XHTML.
+var voidElements = map[string]bool{}
+
+func init() {
+	for _, name := range xml.HTMLAutoClose {
+		voidElements[name] = true
+	}
+}
+
+// dropElements are left out of chapters with their content.
+var dropElements = map[string]bool{"script": true, "noscript": true, "iframe": true}
+
+// Rewriter maps the URL in an element's attribute, such as the href of an
+// a or the src of an img, to its place in the book. Returning "" removes
+// the attribute, and returning false leaves the element out.
+type Rewriter func(element, attr, value string) (string, bool)
+
+// XHTML converts an HTML fragment to well-formed XHTML: void elements are
+// self-closed, unclosed elements are closed, HTML entities become
+// characters, and comments, scripts and frames are dropped. rewrite, if not
+// nil, is called for every href and src attribute.
+func XHTML(html string, rewrite Rewriter) (string, error) {
+	d := xml.NewDecoder(strings.NewReader(html))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+
+	type open struct {
+		name string
+		drop bool
+	}
+	var (
+		b       strings.Builder
+		stack   []open
+		dropped int
+	)
+	closeTo := func(i int) {
+		for len(stack) > i {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if top.drop {
+				dropped--
+			} else if dropped == 0 {
+				b.WriteString("</" + top.name + ">")
+			}
+		}
+	}
+
+	for {
+		token, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := qualified(t.Name)
+			drop := dropElements[strings.ToLower(name)]
+			attrs := make([]xml.Attr, 0, len(t.Attr))
+			seen := map[string]bool{}
+			for _, attr := range t.Attr {
+				key := qualified(attr.Name)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if rewrite != nil && (key == "href" || key == "src") {
+					value, ok := rewrite(name, key, attr.Value)
+					if !ok {
+						drop = true
+					}
+					if value == "" {
+						continue
+					}
+					attr.Value = value
+				}
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: key}, Value: attr.Value})
+			}
+
+			void := voidElements[strings.ToLower(name)]
+			if void {
+				if !drop && dropped == 0 {
+					writeStart(&b, name, attrs, true)
+				}
+				continue
+			}
+			stack = append(stack, open{name: name, drop: drop})
+			if drop {
+				dropped++
+			} else if dropped == 0 {
+				writeStart(&b, name, attrs, false)
+			}
+		case xml.EndElement:
+			name := qualified(t.Name)
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == name {
+					closeTo(i)
+					break
+				}
+			}
+		case xml.CharData:
+			if dropped == 0 {
+				b.WriteString(escape(string(t), false))
+			}
+		}
+	}
+	closeTo(0)
+	return b.String(), nil
+}
+
+func qualified(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+func writeStart(b *strings.Builder, name string, attrs []xml.Attr, void bool) {
+	b.WriteString("<" + name)
+	for _, attr := range attrs {
+		b.WriteString(" " + attr.Name.Local + `="` + escape(attr.Value, true) + `"`)
+	}
+	if void {
+		b.WriteString("/>")
+	} else {
+		b.WriteString(">")
+	}
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// escape escapes text for XML content, or for a double-quoted attribute.
+func escape(s string, attr bool) string {
+	if attr {
+		return attrEscaper.Replace(s)
+	}
+	return textEscaper.Replace(s)
+}
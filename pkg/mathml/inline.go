@@ -0,0 +1,67 @@
+package mathml
+
+// Segment is a run of text or of inline math, as found by SplitInline.
+type Segment struct {
+	// Text is the segment's source; for math, without its $ delimiters.
+	Text string
+	Math bool
+}
+
+// SplitInline splits text into plain text and the inline math between
+// single $ signs, following Pandoc's rules so that prices aren't read as
+// math: the opening $ must be followed by a non-space, and the next $ closes
+// the math only if it follows a non-space other than \ and isn't followed
+// by a digit. Otherwise the opening $ is plain text. \$ is an escaped dollar
+// sign, and $$ is left to display math; both stay in the plain text as they
+// are.
+func SplitInline(text string) []Segment {
+	var segments []Segment
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+		case text[i] == '$' && i+1 < len(text) && text[i+1] == '$':
+			for i+1 < len(text) && text[i+1] == '$' {
+				i++
+			}
+		case text[i] == '$':
+			end := closingDollar(text, i)
+			if end < 0 {
+				continue
+			}
+			if i > start {
+				segments = append(segments, Segment{Text: text[start:i]})
+			}
+			segments = append(segments, Segment{Text: text[i+1 : end], Math: true})
+			start, i = end+1, end
+		}
+	}
+	if start < len(text) {
+		segments = append(segments, Segment{Text: text[start:]})
+	}
+	return segments
+}
+
+// closingDollar returns the index of the $ that closes the inline math
+// opened at text[i], or -1 if the $ at text[i] doesn't open math.
+func closingDollar(text string, i int) int {
+	if i+1 >= len(text) || isSpace(text[i+1]) {
+		return -1
+	}
+	end := i + 1
+	for end < len(text) && text[end] != '$' {
+		end++
+	}
+	if end == len(text) || isSpace(text[end-1]) || text[end-1] == '\\' {
+		return -1
+	}
+	if end+1 < len(text) && (text[end+1] >= '0' && text[end+1] <= '9' || text[end+1] == '$') {
+		return -1
+	}
+	return end
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
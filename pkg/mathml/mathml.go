@@ -0,0 +1,551 @@
+// Package mathml converts a practical subset of LaTeX math to MathML, so
+// pages can show formulas without KaTeX or MathJax in the browser.
+package mathml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Convert renders a LaTeX math expression, without its $ delimiters, as a
+// <math> element. display selects block layout, as for $$...$$. Unsupported
+// commands and malformed input are reported as errors.
+func Convert(tex string, display bool) (string, error) {
+	p := &parser{src: tex, display: display}
+
+	body, err := p.parseRow(func(t token) bool { return t.kind == tokEOF })
+	if err != nil {
+		return "", fmt.Errorf("math \"%s\": %w", strings.TrimSpace(tex), err)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString("><semantics>")
+	b.WriteString(mrow(body))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</annotation></semantics></math>")
+	return b.String(), nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokChar
+	tokCommand
+	tokOpen
+	tokClose
+	tokSup
+	tokSub
+	tokAlign
+	tokNewline
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	src     string
+	pos     int
+	display bool
+	// style is the letter style applied by \mathbf and friends.
+	style string
+}
+
+// node is a rendered MathML element plus what the script parser needs to
+// know about it.
+type node struct {
+	markup string
+	// limits places sub- and superscripts below and above the node.
+	limits bool
+}
+
+func (p *parser) next() token {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		return token{kind: tokEOF}
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+
+	switch r {
+	case '{':
+		return token{kind: tokOpen, text: "{"}
+	case '}':
+		return token{kind: tokClose, text: "}"}
+	case '^':
+		return token{kind: tokSup, text: "^"}
+	case '_':
+		return token{kind: tokSub, text: "_"}
+	case '&':
+		return token{kind: tokAlign, text: "&"}
+	case '\\':
+		return p.command()
+	}
+	return token{kind: tokChar, text: string(r)}
+}
+
+func (p *parser) command() token {
+	if p.pos >= len(p.src) {
+		return token{kind: tokChar, text: `\`}
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		// A single non-letter, as in \{ or \,
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+		if p.src[start:p.pos] == `\` {
+			return token{kind: tokNewline, text: `\\`}
+		}
+	}
+	return token{kind: tokCommand, text: p.src[start:p.pos]}
+}
+
+func (p *parser) peek() token {
+	pos := p.pos
+	t := p.next()
+	p.pos = pos
+	return t
+}
+
+// parseRow parses nodes until stop matches the next token, which is left
+// unconsumed.
+func (p *parser) parseRow(stop func(token) bool) ([]string, error) {
+	var row []string
+	for {
+		t := p.peek()
+		if stop(t) {
+			return row, nil
+		}
+
+		switch t.kind {
+		case tokEOF:
+			return nil, fmt.Errorf("unexpected end of input")
+		case tokClose:
+			return nil, fmt.Errorf("unexpected }")
+		case tokAlign:
+			return nil, fmt.Errorf("& outside of an environment")
+		case tokNewline:
+			return nil, fmt.Errorf(`\\ outside of an environment`)
+		}
+
+		n, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, n)
+	}
+}
+
+// parseScripted parses a node with its optional sub- and superscripts.
+func (p *parser) parseScripted() (string, error) {
+	var base node
+	if t := p.peek(); t.kind != tokSup && t.kind != tokSub {
+		var err error
+		if base, err = p.parseAtom(); err != nil {
+			return "", err
+		}
+	} else {
+		base = node{markup: "<mrow></mrow>"}
+	}
+
+	var sub, sup string
+	for {
+		t := p.peek()
+		if t.kind != tokSub && t.kind != tokSup {
+			break
+		}
+		p.next()
+
+		script, err := p.parseArgument()
+		if err != nil {
+			return "", err
+		}
+		if t.kind == tokSub {
+			if sub != "" {
+				return "", fmt.Errorf("double subscript")
+			}
+			sub = script
+		} else {
+			if sup != "" {
+				return "", fmt.Errorf("double superscript")
+			}
+			sup = script
+		}
+	}
+
+	under, over, both := "msub", "msup", "msubsup"
+	if base.limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+
+	switch {
+	case sub != "" && sup != "":
+		return "<" + both + ">" + base.markup + sub + sup + "</" + both + ">", nil
+	case sub != "":
+		return "<" + under + ">" + base.markup + sub + "</" + under + ">", nil
+	case sup != "":
+		return "<" + over + ">" + base.markup + sup + "</" + over + ">", nil
+	}
+	return base.markup, nil
+}
+
+// parseArgument parses a command argument or script: a braced group or a
+// single atom.
+func (p *parser) parseArgument() (string, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokEOF, tokClose, tokAlign, tokNewline, tokSup, tokSub:
+		return "", fmt.Errorf("missing argument")
+	}
+	n, err := p.parseAtom()
+	return n.markup, err
+}
+
+func (p *parser) parseGroup() (string, error) {
+	row, err := p.parseRow(func(t token) bool { return t.kind == tokClose })
+	if err != nil {
+		return "", err
+	}
+	p.next()
+	return mrow(row), nil
+}
+
+func (p *parser) parseAtom() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokOpen:
+		group, err := p.parseGroup()
+		return node{markup: group}, err
+	case tokChar:
+		return p.parseChar(t.text), nil
+	case tokCommand:
+		return p.parseCommand(t.text)
+	}
+	return node{}, fmt.Errorf("unexpected %s", t.text)
+}
+
+func (p *parser) parseChar(c string) node {
+	r, _ := utf8.DecodeRuneInString(c)
+
+	switch {
+	case unicode.IsDigit(r):
+		number := c
+		for p.pos < len(p.src) {
+			d := p.src[p.pos]
+			if d >= '0' && d <= '9' || d == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9' {
+				number += string(d)
+				p.pos++
+				continue
+			}
+			break
+		}
+		return node{markup: element("mn", p.styled(number))}
+	case unicode.IsLetter(r):
+		return node{markup: p.identifier(c)}
+	case c == "'":
+		return node{markup: element("mo", "′")}
+	case c == "-":
+		return node{markup: element("mo", "−")}
+	}
+	return node{markup: element("mo", c)}
+}
+
+func (p *parser) identifier(text string) string {
+	if p.style == "mathrm" {
+		return `<mi mathvariant="normal">` + html.EscapeString(text) + "</mi>"
+	}
+	return element("mi", p.styled(text))
+}
+
+func (p *parser) styled(text string) string {
+	style, ok := letterStyles[p.style]
+	if !ok {
+		return text
+	}
+	return strings.Map(style.apply, text)
+}
+
+func (p *parser) parseCommand(name string) (node, error) {
+	if s, ok := identifiers[name]; ok {
+		return node{markup: p.identifier(s)}, nil
+	}
+	if s, ok := operators[name]; ok {
+		return node{markup: element("mo", s)}, nil
+	}
+	if s, ok := largeOperators[name]; ok {
+		return node{markup: `<mo largeop="true" movablelimits="true">` + s + "</mo>", limits: true}, nil
+	}
+	if s, ok := integrals[name]; ok {
+		return node{markup: `<mo largeop="true">` + s + "</mo>"}, nil
+	}
+	if functions[name] {
+		return node{markup: `<mi mathvariant="normal">` + name + `</mi>`, limits: limitFunctions[name]}, nil
+	}
+	if width, ok := spaces[name]; ok {
+		return node{markup: `<mspace width="` + width + `"></mspace>`}, nil
+	}
+	if accent, ok := accents[name]; ok {
+		arg, err := p.parseArgument()
+		if err != nil {
+			return node{}, fmt.Errorf(`\%s: %w`, name, err)
+		}
+		if name == "underline" {
+			return node{markup: `<munder accentunder="true">` + arg + `<mo stretchy="true">` + accent + "</mo></munder>"}, nil
+		}
+		stretchy := "false"
+		if strings.HasPrefix(name, "wide") || strings.HasPrefix(name, "over") {
+			stretchy = "true"
+		}
+		return node{markup: `<mover accent="true">` + arg + `<mo stretchy="` + stretchy + `">` + html.EscapeString(accent) + "</mo></mover>"}, nil
+	}
+	if _, ok := letterStyles[name]; ok || name == "mathrm" || name == "mathit" || name == "operatorname" {
+		return p.parseStyled(name)
+	}
+
+	switch name {
+	case "{", "}", "|", "#", "%", "$", "&", "_":
+		symbol := name
+		if name == "|" {
+			symbol = "‖"
+		}
+		return node{markup: element("mo", symbol)}, nil
+	case "frac", "dfrac", "tfrac":
+		num, err := p.parseArgument()
+		if err != nil {
+			return node{}, fmt.Errorf(`\%s numerator: %w`, name, err)
+		}
+		den, err := p.parseArgument()
+		if err != nil {
+			return node{}, fmt.Errorf(`\%s denominator: %w`, name, err)
+		}
+		return node{markup: "<mfrac>" + num + den + "</mfrac>"}, nil
+	case "binom":
+		n, err := p.parseArgument()
+		if err != nil {
+			return node{}, fmt.Errorf(`\binom: %w`, err)
+		}
+		k, err := p.parseArgument()
+		if err != nil {
+			return node{}, fmt.Errorf(`\binom: %w`, err)
+		}
+		return node{markup: `<mrow><mo>(</mo><mfrac linethickness="0">` + n + k + `</mfrac><mo>)</mo></mrow>`}, nil
+	case "sqrt":
+		return p.parseSqrt()
+	case "text", "textrm", "mbox":
+		text, err := p.rawGroup()
+		if err != nil {
+			return node{}, fmt.Errorf(`\%s: %w`, name, err)
+		}
+		return node{markup: element("mtext", text)}, nil
+	case "left":
+		return p.parseFenced()
+	case "begin":
+		return p.parseEnvironment()
+	case "right":
+		return node{}, fmt.Errorf(`\right without \left`)
+	case "end":
+		return node{}, fmt.Errorf(`\end without \begin`)
+	}
+
+	return node{}, fmt.Errorf(`unsupported command \%s`, name)
+}
+
+func (p *parser) parseStyled(name string) (node, error) {
+	if name == "operatorname" {
+		text, err := p.rawGroup()
+		if err != nil {
+			return node{}, fmt.Errorf(`\operatorname: %w`, err)
+		}
+		return node{markup: `<mi mathvariant="normal">` + html.EscapeString(text) + `</mi>`}, nil
+	}
+
+	outer := p.style
+	p.style = name
+	defer func() { p.style = outer }()
+
+	arg, err := p.parseArgument()
+	if err != nil {
+		return node{}, fmt.Errorf(`\%s: %w`, name, err)
+	}
+	return node{markup: arg}, nil
+}
+
+func (p *parser) parseSqrt() (node, error) {
+	var index string
+	if p.peek().text == "[" {
+		p.next()
+		row, err := p.parseRow(func(t token) bool { return t.kind == tokChar && t.text == "]" })
+		if err != nil {
+			return node{}, fmt.Errorf(`\sqrt index: %w`, err)
+		}
+		p.next()
+		index = mrow(row)
+	}
+
+	radicand, err := p.parseArgument()
+	if err != nil {
+		return node{}, fmt.Errorf(`\sqrt: %w`, err)
+	}
+	if index != "" {
+		return node{markup: "<mroot>" + radicand + index + "</mroot>"}, nil
+	}
+	return node{markup: "<msqrt>" + radicand + "</msqrt>"}, nil
+}
+
+func (p *parser) parseFenced() (node, error) {
+	open, err := p.delimiter("left")
+	if err != nil {
+		return node{}, err
+	}
+
+	row, err := p.parseRow(func(t token) bool { return t.kind == tokCommand && t.text == "right" })
+	if err != nil {
+		return node{}, fmt.Errorf(`\left%s: %w`, open, err)
+	}
+	p.next()
+
+	close, err := p.delimiter("right")
+	if err != nil {
+		return node{}, err
+	}
+
+	return node{markup: "<mrow>" + fence(open) + strings.Join(row, "") + fence(close) + "</mrow>"}, nil
+}
+
+func (p *parser) delimiter(command string) (string, error) {
+	t := p.next()
+	key := t.text
+	if t.kind == tokCommand {
+		key = `\` + t.text
+	}
+	d, ok := delimiters[key]
+	if !ok {
+		return "", fmt.Errorf(`unsupported delimiter %q after \%s`, key, command)
+	}
+	return d, nil
+}
+
+func fence(d string) string {
+	if d == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(d) + "</mo>"
+}
+
+func (p *parser) parseEnvironment() (node, error) {
+	name, err := p.rawGroup()
+	if err != nil {
+		return node{}, fmt.Errorf(`\begin: %w`, err)
+	}
+	fences, ok := matrixFences[name]
+	if !ok {
+		return node{}, fmt.Errorf("unsupported environment %q", name)
+	}
+
+	endOfCell := func(t token) bool {
+		return t.kind == tokAlign || t.kind == tokNewline || t.kind == tokCommand && t.text == "end"
+	}
+
+	var rows []string
+	var cells []string
+	for {
+		cell, err := p.parseRow(endOfCell)
+		if err != nil {
+			return node{}, fmt.Errorf("%s: %w", name, err)
+		}
+		cells = append(cells, "<mtd>"+mrow(cell)+"</mtd>")
+
+		t := p.next()
+		if t.kind == tokAlign {
+			continue
+		}
+		if t.kind == tokNewline || len(cells) > 1 || cell != nil {
+			rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+		}
+		cells = nil
+		if t.kind == tokNewline {
+			continue
+		}
+
+		end, err := p.rawGroup()
+		if err != nil {
+			return node{}, fmt.Errorf(`\end: %w`, err)
+		}
+		if end != name {
+			return node{}, fmt.Errorf(`\begin{%s} ended by \end{%s}`, name, end)
+		}
+		break
+	}
+
+	table := "<mtable"
+	switch name {
+	case "cases":
+		table += ` columnalign="left left"`
+	case "aligned":
+		table += ` columnalign="right left" columnspacing="0em"`
+	}
+	table += ">" + strings.Join(rows, "") + "</mtable>"
+
+	return node{markup: "<mrow>" + fence(fences[0]) + table + fence(fences[1]) + "</mrow>"}, nil
+}
+
+// rawGroup reads the literal text of a braced group, as used by \text and
+// environment names.
+func (p *parser) rawGroup() (string, error) {
+	if t := p.next(); t.kind != tokOpen {
+		return "", fmt.Errorf("expected {")
+	}
+
+	depth := 1
+	start := p.pos
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				text := p.src[start:p.pos]
+				p.pos++
+				return text, nil
+			}
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("missing }")
+}
+
+func element(name, text string) string {
+	return "<" + name + ">" + html.EscapeString(text) + "</" + name + ">"
+}
+
+func mrow(row []string) string {
+	if len(row) == 1 {
+		return row[0]
+	}
+	return "<mrow>" + strings.Join(row, "") + "</mrow>"
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,86 @@
+package mathml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		tex     string
+		display bool
+		want    string
+		wantErr string
+	}{
+		{name: "superscript", tex: "x^2", want: "<msup><mi>x</mi><mn>2</mn></msup>"},
+		{name: "subscript on symbol", tex: `\alpha_i`, want: "<msub><mi>α</mi><mi>i</mi></msub>"},
+		{name: "fraction", tex: `\frac{1}{2}`, want: "<mfrac><mn>1</mn><mn>2</mn></mfrac>"},
+		{name: "styled letter", tex: `\mathbf{v}`, want: "<mi>𝐯</mi>"},
+		{name: "escaped braces", tex: `\{a\}`, want: "<mo>{</mo><mi>a</mi><mo>}</mo>"},
+		{name: "escaped dollar", tex: `\$5`, want: "<mo>$</mo><mn>5</mn>"},
+		{name: "html escaped", tex: "a < b", want: "<mo>&lt;</mo>"},
+		{name: "annotation escaped", tex: "a<b", want: `<annotation encoding="application/x-tex">a&lt;b</annotation>`},
+		{name: "display", tex: "x", display: true, want: `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`},
+		{name: "matrix", tex: `\begin{matrix}a&b\\c&d\end{matrix}`, want: "<mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr>"},
+		{name: "empty", tex: "", want: "<mrow></mrow>"},
+		{name: "unclosed group", tex: "{a", wantErr: "unexpected end of input"},
+		{name: "unopened group", tex: "a}", wantErr: "unexpected }"},
+		{name: "missing script", tex: "x^", wantErr: "missing argument"},
+		{name: "missing denominator", tex: `\frac{1}`, wantErr: `\frac denominator: missing argument`},
+		{name: "unknown command", tex: `\unknown`, wantErr: `unsupported command \unknown`},
+		{name: "unclosed left", tex: `\left( x`, wantErr: "unexpected end of input"},
+		{name: "unclosed environment", tex: `\begin{matrix}a`, wantErr: "matrix: unexpected end of input"},
+		{name: "newline outside environment", tex: `\\`, wantErr: `\\ outside of an environment`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.tex, tt.display)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Convert(%q) error = %v, want %q", tt.tex, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Convert(%q) error = %v", tt.tex, err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("Convert(%q) = %s, want it to contain %s", tt.tex, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitInline(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Segment
+	}{
+		{name: "no math", text: "plain text", want: []Segment{{Text: "plain text"}}},
+		{name: "math", text: "a $x^2$ b", want: []Segment{{Text: "a "}, {Text: "x^2", Math: true}, {Text: " b"}}},
+		{name: "only math", text: "$x$", want: []Segment{{Text: "x", Math: true}}},
+		{name: "two expressions", text: "$a$ and $b$", want: []Segment{{Text: "a", Math: true}, {Text: " and "}, {Text: "b", Math: true}}},
+		{name: "prices", text: "$5 and $10", want: []Segment{{Text: "$5 and $10"}}},
+		{name: "closing before digit", text: "$5 to 6$7", want: []Segment{{Text: "$5 to 6$7"}}},
+		{name: "space after opening", text: "$ x$", want: []Segment{{Text: "$ x$"}}},
+		{name: "space before closing", text: "$x $", want: []Segment{{Text: "$x $"}}},
+		{name: "price before math", text: "costs $ 5, $x$", want: []Segment{{Text: "costs $ 5, "}, {Text: "x", Math: true}}},
+		{name: "escaped dollar", text: `\$5 and $x$`, want: []Segment{{Text: `\$5 and `}, {Text: "x", Math: true}}},
+		{name: "escaped closing", text: `$x\$`, want: []Segment{{Text: `$x\$`}}},
+		{name: "display math", text: "$$x$$", want: []Segment{{Text: "$$x$$"}}},
+		{name: "across lines", text: "$a +\nb$", want: []Segment{{Text: "a +\nb", Math: true}}},
+		{name: "unterminated", text: "$x", want: []Segment{{Text: "$x"}}},
+		{name: "lone dollar", text: "$", want: []Segment{{Text: "$"}}},
+		{name: "empty", text: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitInline(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitInline(%q) = %#v, want %#v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package mathml
+
+// identifiers are commands rendered as <mi>.
+var identifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"omicron": "ο", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅",
+	"varnothing": "∅", "ell": "ℓ", "hbar": "ℏ", "Re": "ℜ", "Im": "ℑ",
+	"aleph": "ℵ", "wp": "℘", "prime": "′",
+}
+
+// operators are commands rendered as <mo>.
+var operators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "odot": "⊙", "wedge": "∧", "land": "∧", "vee": "∨",
+	"lor": "∨", "neg": "¬", "lnot": "¬", "setminus": "∖",
+
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"ll": "≪", "gg": "≫", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"simeq": "≃", "cong": "≅", "propto": "∝", "mid": "∣", "parallel": "∥",
+	"perp": "⊥", "in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂",
+	"supset": "⊃", "subseteq": "⊆", "supseteq": "⊇", "cup": "∪", "cap": "∩",
+	"forall": "∀", "exists": "∃", "nexists": "∄",
+
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺", "mapsto": "↦",
+	"uparrow": "↑", "downarrow": "↓",
+
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱", "dots": "…",
+	"colon": ":",
+}
+
+// largeOperators take their limits above and below in display math.
+var largeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigvee": "⋁", "bigwedge": "⋀",
+}
+
+// integrals are large operators that keep their limits as scripts.
+var integrals = map[string]string{
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+// functions are upright operator names. Those in limitFunctions take their
+// limits below in display math.
+var functions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true,
+	"csc": true, "arcsin": true, "arccos": true, "arctan": true, "sinh": true,
+	"cosh": true, "tanh": true, "log": true, "ln": true, "lg": true,
+	"exp": true, "det": true, "dim": true, "ker": true, "deg": true,
+	"arg": true, "gcd": true, "lim": true, "max": true, "min": true,
+	"sup": true, "inf": true, "Pr": true,
+}
+
+var limitFunctions = map[string]bool{
+	"lim": true, "max": true, "min": true, "sup": true, "inf": true,
+	"det": true, "gcd": true, "Pr": true,
+}
+
+// accents are placed over (or, for underline, under) their argument.
+var accents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→",
+	"overrightarrow": "→", "tilde": "~", "widetilde": "~", "dot": "˙",
+	"ddot": "¨", "check": "ˇ", "breve": "˘", "acute": "´", "grave": "`",
+	"underline": "_",
+}
+
+// spaces maps spacing commands to their width.
+var spaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	" ": "0.25em", "!": "-0.1667em", "quad": "1em", "qquad": "2em",
+}
+
+// delimiters may follow \left and \right.
+var delimiters = map[string]string{
+	"(": "(", ")": ")", "[": "[", "]": "]", "|": "|", "/": "/", ".": "",
+	`\{`: "{", `\}`: "}", `\|`: "‖", `\lbrace`: "{", `\rbrace`: "}",
+	`\langle`: "⟨", `\rangle`: "⟩", `\lfloor`: "⌊", `\rfloor`: "⌋",
+	`\lceil`: "⌈", `\rceil`: "⌉", `\vert`: "|", `\Vert`: "‖",
+}
+
+// matrixFences are the delimiters drawn around each matrix environment.
+var matrixFences = map[string][2]string{
+	"matrix":  {"", ""},
+	"pmatrix": {"(", ")"},
+	"bmatrix": {"[", "]"},
+	"Bmatrix": {"{", "}"},
+	"vmatrix": {"|", "|"},
+	"Vmatrix": {"‖", "‖"},
+	"cases":   {"{", ""},
+	"aligned": {"", ""},
+}
+
+// Letter styles map A, a and 0 to the start of their Unicode Mathematical
+// Alphanumeric Symbols block. Letters missing from a block live in the
+// Letterlike Symbols block and are listed as exceptions.
+type letterStyle struct {
+	upper, lower, digit rune
+	exceptions          map[rune]rune
+}
+
+var letterStyles = map[string]letterStyle{
+	"mathbf": {upper: 0x1D400, lower: 0x1D41A, digit: 0x1D7CE},
+	"mathbb": {upper: 0x1D538, lower: 0x1D552, digit: 0x1D7D8, exceptions: map[rune]rune{
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	}},
+	"mathcal": {upper: 0x1D49C, lower: 0x1D4B6, exceptions: map[rune]rune{
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	}},
+	"mathfrak": {upper: 0x1D504, lower: 0x1D51E, exceptions: map[rune]rune{
+		'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+	}},
+}
+
+func (s letterStyle) apply(r rune) rune {
+	if e, ok := s.exceptions[r]; ok {
+		return e
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return s.upper + r - 'A'
+	case r >= 'a' && r <= 'z':
+		return s.lower + r - 'a'
+	case r >= '0' && r <= '9' && s.digit != 0:
+		return s.digit + r - '0'
+	}
+	return r
+}
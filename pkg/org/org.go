@@ -0,0 +1,506 @@
+// Package org renders a practical subset of Emacs org-mode to HTML:
+// keywords, headings, paragraphs, lists, links, emphasis, tables, and src,
+// example and quote blocks.
+package org
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	sitetext "github.com/ahoglund/go-static/pkg/text"
+)
+
+// Options customize the rendering of a document.
+type Options struct {
+	// Link rewrites link destinations, e.g. to turn "file:other.org" into the
+	// other page's URL. The "file:" prefix is removed before Link is called.
+	Link func(dest string) string
+}
+
+var (
+	keywordPattern  = regexp.MustCompile(`^\s*#\+([A-Za-z_][\w-]*):\s*(.*?)\s*$`)
+	headingPattern  = regexp.MustCompile(`^(\*+)\s+(.*?)\s*$`)
+	headingTags     = regexp.MustCompile(`\s+:[\w@#%:]+:$`)
+	headingTodo     = regexp.MustCompile(`^(?:TODO|DONE)\s+`)
+	headingPriority = regexp.MustCompile(`^\[#[A-Z]\]\s+`)
+	beginPattern    = regexp.MustCompile(`(?i)^\s*#\+begin_(\w+)\s*(.*?)\s*$`)
+	drawerPattern   = regexp.MustCompile(`^\s*:[A-Za-z_-]+:\s*$`)
+	drawerEnd       = regexp.MustCompile(`(?i)^\s*:end:\s*$`)
+	listPattern     = regexp.MustCompile(`^(\s*)([-+]|\d+[.)]|\*)(?:\s+(.*))?$`)
+	checkboxPattern = regexp.MustCompile(`^\[([ Xx-])\]\s+`)
+	rulePattern     = regexp.MustCompile(`^\s*-{5,}\s*$`)
+	fixedPattern    = regexp.MustCompile(`^\s*:(?: |$)`)
+	urlPattern      = regexp.MustCompile(`^https?://[^\s<>\[\]]*[^\s<>\[\].,;:!?'")]`)
+	imagePattern    = regexp.MustCompile(`(?i)\.(?:png|jpe?g|gif|svg|webp|avif)$`)
+)
+
+// Keywords returns the document's #+KEY: value lines, keyed by lowercase
+// name. Repeated keywords are joined with a space.
+func Keywords(source string) map[string]string {
+	keywords := map[string]string{}
+	lines := strings.Split(source, "\n")
+	for i := 0; i < len(lines); i++ {
+		if end := blockEnd(lines, i); end > 0 {
+			i = end
+			continue
+		}
+		m := keywordPattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		key := strings.ToLower(m[1])
+		if prev, ok := keywords[key]; ok && prev != "" {
+			keywords[key] = prev + " " + m[2]
+		} else {
+			keywords[key] = m[2]
+		}
+	}
+	return keywords
+}
+
+// ToHTML renders an org-mode document. Keyword lines, comments and drawers
+// are left out of the output.
+func ToHTML(source string, opts Options) string {
+	r := &renderer{opts: opts}
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+	return r.blocks(lines)
+}
+
+type renderer struct {
+	opts Options
+}
+
+// blocks renders a sequence of block-level elements.
+func (r *renderer) blocks(lines []string) string {
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case blockEnd(lines, i) > 0:
+			i = r.block(&b, lines, i)
+
+		case keywordPattern.MatchString(line):
+			if m := keywordPattern.FindStringSubmatch(line); strings.EqualFold(m[1], "html") {
+				b.WriteString(m[2] + "\n")
+			}
+			i++
+
+		case trimmed == "#" || strings.HasPrefix(trimmed, "# "):
+			i++
+
+		case drawerPattern.MatchString(line):
+			j := i + 1
+			for j < len(lines) && !drawerEnd.MatchString(lines[j]) {
+				j++
+			}
+			if j == len(lines) {
+				// Not a drawer after all, just a paragraph like ":word:".
+				i = r.paragraph(&b, lines, i)
+			} else {
+				i = j + 1
+			}
+
+		case headingPattern.MatchString(line):
+			r.heading(&b, line)
+			i++
+
+		case strings.HasPrefix(trimmed, "|"):
+			i = r.table(&b, lines, i)
+
+		case rulePattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case fixedPattern.MatchString(line):
+			var text []string
+			for ; i < len(lines) && fixedPattern.MatchString(lines[i]); i++ {
+				text = append(text, strings.TrimPrefix(strings.TrimPrefix(strings.TrimLeft(lines[i], " \t"), ":"), " "))
+			}
+			b.WriteString("<pre class=\"example\">" + html.EscapeString(strings.Join(text, "\n")) + "</pre>\n")
+
+		case isListItem(line):
+			i = r.list(&b, lines, i)
+
+		default:
+			i = r.paragraph(&b, lines, i)
+		}
+	}
+	return b.String()
+}
+
+// block renders a #+BEGIN_x ... #+END_x block starting at lines[i] and
+// returns the index of the line after it.
+func (r *renderer) block(b *strings.Builder, lines []string, i int) int {
+	m := beginPattern.FindStringSubmatch(lines[i])
+	kind, args := strings.ToLower(m[1]), m[2]
+
+	end := blockEnd(lines, i)
+	body := lines[i+1 : end]
+	next := end + 1
+
+	switch kind {
+	case "src", "example":
+		text := make([]string, len(body))
+		for j, line := range body {
+			// Org escapes lines that would otherwise be read as syntax with
+			// a leading comma.
+			if t := strings.TrimLeft(line, " \t"); strings.HasPrefix(t, ",*") || strings.HasPrefix(t, ",#+") {
+				line = line[:len(line)-len(t)] + t[1:]
+			}
+			text[j] = line
+		}
+		code := html.EscapeString(sitetext.Dedent(strings.Join(text, "\n")))
+		if kind == "example" {
+			b.WriteString("<pre class=\"example\">" + code + "</pre>\n")
+			break
+		}
+		lang := strings.Fields(args)
+		if len(lang) > 0 {
+			b.WriteString("<pre><code class=\"language-" + html.EscapeString(lang[0]) + "\">" + code + "</code></pre>\n")
+		} else {
+			b.WriteString("<pre><code>" + code + "</code></pre>\n")
+		}
+	case "quote":
+		b.WriteString("<blockquote>\n" + r.blocks(body) + "</blockquote>\n")
+	case "export":
+		if strings.EqualFold(strings.TrimSpace(args), "html") {
+			b.WriteString(strings.Join(body, "\n") + "\n")
+		}
+	case "comment":
+	case "verse":
+		var text []string
+		for _, line := range body {
+			text = append(text, r.inline(strings.TrimSpace(line)))
+		}
+		b.WriteString("<p class=\"verse\">" + strings.Join(text, "<br>\n") + "</p>\n")
+	default:
+		b.WriteString("<div class=\"" + html.EscapeString(kind) + "\">\n" + r.blocks(body) + "</div>\n")
+	}
+	return next
+}
+
+func (r *renderer) heading(b *strings.Builder, line string) {
+	m := headingPattern.FindStringSubmatch(line)
+	level := len(m[1])
+	if level > 6 {
+		level = 6
+	}
+	text := headingTags.ReplaceAllString(m[2], "")
+	text = headingTodo.ReplaceAllString(text, "")
+	text = headingPriority.ReplaceAllString(text, "")
+
+	tag := "h" + string(rune('0'+level))
+	b.WriteString("<" + tag + " id=\"" + sitetext.Slugify(text) + "\">" + r.inline(text) + "</" + tag + ">\n")
+}
+
+// paragraph renders the lines from lines[i] up to the next blank line or
+// other block as a paragraph.
+func (r *renderer) paragraph(b *strings.Builder, lines []string, i int) int {
+	text := []string{strings.TrimSpace(lines[i])}
+	for i++; i < len(lines) && !startsBlock(lines[i]); i++ {
+		text = append(text, strings.TrimSpace(lines[i]))
+	}
+	b.WriteString("<p>" + r.inline(strings.Join(text, "\n")) + "</p>\n")
+	return i
+}
+
+// table renders consecutive |-prefixed lines. Rows above the first
+// |---+---| separator form the header.
+func (r *renderer) table(b *strings.Builder, lines []string, i int) int {
+	var rows [][]string
+	header, separated := 0, false
+	for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "|-") {
+			if !separated {
+				header, separated = len(rows), true
+			}
+			continue
+		}
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+		cells := strings.Split(line, "|")
+		for j := range cells {
+			cells[j] = strings.TrimSpace(cells[j])
+		}
+		rows = append(rows, cells)
+	}
+	if header == len(rows) {
+		header = 0
+	}
+
+	b.WriteString("<table>\n")
+	for j, row := range rows {
+		if j == 0 && header > 0 {
+			b.WriteString("<thead>\n")
+		}
+		if j == header {
+			b.WriteString("<tbody>\n")
+		}
+		cell := "td"
+		if j < header {
+			cell = "th"
+		}
+		b.WriteString("<tr>")
+		for _, c := range row {
+			b.WriteString("<" + cell + ">" + r.inline(c) + "</" + cell + ">")
+		}
+		b.WriteString("</tr>\n")
+		if j == header-1 {
+			b.WriteString("</thead>\n")
+		}
+	}
+	if len(rows) > header {
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return i
+}
+
+// list renders the list whose first item is at lines[i], including nested
+// lists, and returns the index of the line after it.
+func (r *renderer) list(b *strings.Builder, lines []string, i int) int {
+	first := listPattern.FindStringSubmatch(lines[i])
+	indent := len(first[1])
+	ordered := isOrdered(first[2])
+	description := !ordered && strings.Contains(first[3], " :: ")
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	} else if description {
+		tag = "dl"
+	}
+	b.WriteString("<" + tag + ">\n")
+
+	for i < len(lines) {
+		m := listPattern.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != indent || !isListItem(lines[i]) {
+			break
+		}
+		// A different kind of marker starts a new list.
+		if isOrdered(m[2]) != ordered || !ordered && strings.Contains(m[3], " :: ") != description {
+			break
+		}
+
+		// The item runs until the next line indented no further than its
+		// marker, skipping blank lines.
+		body := []string{m[3]}
+		j := i + 1
+		for ; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) != "" && indentOf(lines[j]) <= indent {
+				break
+			}
+			body = append(body, lines[j])
+		}
+		for len(body) > 1 && strings.TrimSpace(body[len(body)-1]) == "" {
+			body = body[:len(body)-1]
+		}
+		r.listItem(b, body, description)
+		i = j
+	}
+
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func (r *renderer) listItem(b *strings.Builder, body []string, description bool) {
+	rest := body[1:]
+
+	// The item's own text is its first line and the continuation lines up to
+	// a blank line or nested block.
+	text := []string{body[0]}
+	for len(rest) > 0 && strings.TrimSpace(rest[0]) != "" && !startsBlock(rest[0]) {
+		text = append(text, strings.TrimSpace(rest[0]))
+		rest = rest[1:]
+	}
+	content := strings.Join(text, "\n")
+
+	prefix := ""
+	if m := checkboxPattern.FindStringSubmatch(content); m != nil {
+		prefix = `<input type="checkbox" disabled> `
+		if m[1] == "X" || m[1] == "x" {
+			prefix = `<input type="checkbox" disabled checked> `
+		}
+		content = content[len(m[0]):]
+	}
+
+	if description {
+		term, desc := content, ""
+		if k := strings.Index(content, " :: "); k >= 0 {
+			term, desc = content[:k], content[k+4:]
+		}
+		b.WriteString("<dt>" + prefix + r.inline(strings.TrimSpace(term)) + "</dt>\n")
+		b.WriteString("<dd>" + r.inline(strings.TrimSpace(desc)) + r.blocks(rest) + "</dd>\n")
+		return
+	}
+	b.WriteString("<li>" + prefix + r.inline(content) + r.blocks(rest) + "</li>\n")
+}
+
+// inline renders the markup within a paragraph: links, emphasis, verbatim
+// text, URLs and \\ line breaks.
+func (r *renderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "[[") {
+			if end := strings.Index(s[i:], "]]"); end > 0 {
+				b.WriteString(r.link(s[i+2 : i+end]))
+				i += end + 2
+				continue
+			}
+		}
+
+		if strings.HasPrefix(s[i:], `\\`) && (i+2 == len(s) || s[i+2] == '\n') {
+			b.WriteString("<br>")
+			i += 2
+			continue
+		}
+
+		if tag, ok := emphasis[s[i]]; ok && canOpen(s, i) {
+			if j := closing(s, i); j > 0 {
+				inner := s[i+1 : j]
+				if tag == "code" {
+					b.WriteString("<code>" + html.EscapeString(inner) + "</code>")
+				} else {
+					b.WriteString("<" + tag + ">" + r.inline(inner) + "</" + tag + ">")
+				}
+				i = j + 1
+				continue
+			}
+		}
+
+		if s[i] == 'h' && (i == 0 || !isWordByte(s[i-1])) {
+			if url := urlPattern.FindString(s[i:]); url != "" {
+				b.WriteString(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(url) + "</a>")
+				i += len(url)
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// link renders the inside of [[dest]] or [[dest][label]].
+func (r *renderer) link(raw string) string {
+	dest, label := raw, ""
+	if k := strings.Index(raw, "]["); k >= 0 {
+		dest, label = raw[:k], raw[k+2:]
+	}
+
+	switch {
+	case strings.HasPrefix(dest, "*"):
+		dest = "#" + sitetext.Slugify(strings.TrimPrefix(dest, "*"))
+	case strings.HasPrefix(dest, "#"):
+	default:
+		dest = strings.TrimPrefix(dest, "file:")
+		if r.opts.Link != nil {
+			dest = r.opts.Link(dest)
+		}
+	}
+
+	if label == "" && imagePattern.MatchString(dest) {
+		return `<img src="` + html.EscapeString(dest) + `" alt="">`
+	}
+	if label == "" {
+		return `<a href="` + html.EscapeString(dest) + `">` + html.EscapeString(strings.TrimPrefix(raw, "file:")) + "</a>"
+	}
+	if imagePattern.MatchString(label) && !strings.Contains(label, " ") {
+		return `<a href="` + html.EscapeString(dest) + `"><img src="` + html.EscapeString(label) + `" alt=""></a>`
+	}
+	return `<a href="` + html.EscapeString(dest) + `">` + r.inline(label) + "</a>"
+}
+
+// emphasis maps org's emphasis markers to HTML elements. "code" content is
+// rendered verbatim.
+var emphasis = map[byte]string{
+	'*': "strong",
+	'/': "em",
+	'_': "u",
+	'+': "del",
+	'=': "code",
+	'~': "code",
+}
+
+// canOpen reports whether the marker at s[i] may start emphasis: it follows
+// whitespace or an opening character and is followed by a non-space.
+func canOpen(s string, i int) bool {
+	if i > 0 && !strings.ContainsRune(" \t\n-({'\"", rune(s[i-1])) {
+		return false
+	}
+	return i+1 < len(s) && !isSpace(s[i+1])
+}
+
+// closing finds the marker that ends the emphasis opened at s[i], or -1.
+func closing(s string, i int) int {
+	for j := i + 2; j < len(s); j++ {
+		if s[j] != s[i] || isSpace(s[j-1]) {
+			continue
+		}
+		if j+1 == len(s) || strings.ContainsRune(" \t\n-.,:!?;'\")}[\\", rune(s[j+1])) {
+			return j
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// blockEnd returns the index of the #+END_x line that closes the
+// #+BEGIN_x line at lines[i], or -1 if lines[i] doesn't begin a block or the
+// block isn't closed. As in Org, an unclosed #+BEGIN_x line is plain text.
+// Blocks of the same kind can't nest, so src blocks can show org source.
+func blockEnd(lines []string, i int) int {
+	m := beginPattern.FindStringSubmatch(lines[i])
+	if m == nil {
+		return -1
+	}
+	end := "#+end_" + strings.ToLower(m[1])
+	for j := i + 1; j < len(lines); j++ {
+		if strings.ToLower(strings.TrimSpace(lines[j])) == end {
+			return j
+		}
+	}
+	return -1
+}
+
+func isOrdered(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+// isListItem reports whether line starts a list item. An unindented "*"
+// starts a heading instead.
+func isListItem(line string) bool {
+	m := listPattern.FindStringSubmatch(line)
+	return m != nil && !(m[2] == "*" && m[1] == "")
+}
+
+// startsBlock reports whether line ends a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" ||
+		headingPattern.MatchString(line) ||
+		beginPattern.MatchString(line) ||
+		keywordPattern.MatchString(line) ||
+		strings.HasPrefix(trimmed, "|") ||
+		rulePattern.MatchString(line) ||
+		fixedPattern.MatchString(line) ||
+		isListItem(line)
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
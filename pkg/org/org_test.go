@@ -0,0 +1,163 @@
+package org
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "emphasis",
+			source: "*bold* /it/ _u_ +del+ =a *b*= ~c~",
+			want:   "<p><strong>bold</strong> <em>it</em> <u>u</u> <del>del</del> <code>a *b*</code> <code>c</code></p>\n",
+		},
+		{
+			name:   "markers inside words and unclosed",
+			source: "a*b*c and *unclosed",
+			want:   "<p>a*b*c and *unclosed</p>\n",
+		},
+		{
+			name:   "html escaped",
+			source: `a < b & "c"`,
+			want:   "<p>a &lt; b &amp; &#34;c&#34;</p>\n",
+		},
+		{
+			name:   "line break",
+			source: "a\\\\\nb",
+			want:   "<p>a<br>\nb</p>\n",
+		},
+		{
+			name:   "links",
+			source: "[[https://e.com][E <x>]] [[file:other.org]] [[*Some Heading]]",
+			want:   `<p><a href="https://e.com">E &lt;x&gt;</a> <a href="other.org">other.org</a> <a href="#some-heading">*Some Heading</a></p>` + "\n",
+		},
+		{
+			name:   "unclosed link",
+			source: "see [[other.org",
+			want:   "<p>see [[other.org</p>\n",
+		},
+		{
+			name:   "bare url",
+			source: "see https://example.com/a.",
+			want:   `<p>see <a href="https://example.com/a">https://example.com/a</a>.</p>` + "\n",
+		},
+		{
+			name:   "heading",
+			source: "* TODO [#A] Title :tag:",
+			want:   `<h1 id="title">Title</h1>` + "\n",
+		},
+		{
+			name:   "src block",
+			source: "#+BEGIN_SRC go\nif a < b {\n}\n#+END_SRC",
+			want:   `<pre><code class="language-go">if a &lt; b {` + "\n}</code></pre>\n",
+		},
+		{
+			name:   "escaped lines in src block",
+			source: "#+BEGIN_SRC org\n,* Heading\n,#+TITLE: x\n#+END_SRC",
+			want:   `<pre><code class="language-org">* Heading` + "\n#+TITLE: x</code></pre>\n",
+		},
+		{
+			name:   "unterminated src block",
+			source: "#+BEGIN_SRC go\nfmt.Println(1)\n\nmore text",
+			want:   "<p>#+BEGIN_SRC go\nfmt.Println(1)</p>\n<p>more text</p>\n",
+		},
+		{
+			name:   "src block in quote",
+			source: "#+begin_quote\n#+begin_src sh\necho\n#+end_src\nafter\n#+end_quote",
+			want:   "<blockquote>\n<pre><code class=\"language-sh\">echo</code></pre>\n<p>after</p>\n</blockquote>\n",
+		},
+		{
+			name:   "drawer",
+			source: ":PROPERTIES:\n:ID: 1\n:END:\ntext",
+			want:   "<p>text</p>\n",
+		},
+		{
+			name:   "unclosed drawer",
+			source: ":word:",
+			want:   "<p>:word:</p>\n",
+		},
+		{
+			name:   "fixed width",
+			source: ": fixed <x>",
+			want:   "<pre class=\"example\">fixed &lt;x&gt;</pre>\n",
+		},
+		{
+			name:   "nested list with checkbox",
+			source: "- a\n- [X] b\n  1. c",
+			want:   "<ul>\n<li>a</li>\n<li><input type=\"checkbox\" disabled checked> b<ol>\n<li>c</li>\n</ol>\n</li>\n</ul>\n",
+		},
+		{
+			name:   "description list",
+			source: "- term :: desc",
+			want:   "<dl>\n<dt>term</dt>\n<dd>desc</dd>\n</dl>\n",
+		},
+		{
+			name:   "table",
+			source: "| a | b |\n|---+---|\n| 1 | 2 |",
+			want:   "<table>\n<thead>\n<tr><th>a</th><th>b</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td>2</td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:   "keywords and comments left out",
+			source: "#+TITLE: T\n# a comment\ntext",
+			want:   "<p>text</p>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToHTML(tt.source, Options{}); got != tt.want {
+				t.Errorf("ToHTML(%q) =\n%s\nwant\n%s", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToHTMLLink(t *testing.T) {
+	got := ToHTML("[[file:other.org][Other]]", Options{Link: func(dest string) string {
+		return "/" + strings.TrimSuffix(dest, ".org") + "/"
+	}})
+	if want := `<p><a href="/other/">Other</a></p>` + "\n"; got != want {
+		t.Errorf("ToHTML() = %q, want %q", got, want)
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   map[string]string
+	}{
+		{
+			name:   "keywords",
+			source: "#+TITLE: Notes\n#+date: 2026-01-02\ntext",
+			want:   map[string]string{"title": "Notes", "date": "2026-01-02"},
+		},
+		{
+			name:   "repeated keyword",
+			source: "#+FILETAGS: a\n#+FILETAGS: b",
+			want:   map[string]string{"filetags": "a b"},
+		},
+		{
+			name:   "keyword in block",
+			source: "#+TITLE: T\n#+BEGIN_SRC org\n#+TITLE: not\n#+END_SRC",
+			want:   map[string]string{"title": "T"},
+		},
+		{
+			name:   "keyword after unterminated block",
+			source: "#+BEGIN_SRC\n#+TITLE: T",
+			want:   map[string]string{"title": "T"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Keywords(tt.source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Keywords(%q) = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// admonition is a callout block, written either GitHub style:
+//
+//	> [!WARNING] Optional title
+//	> Body
+//
+// or fenced:
+//
+//	:::warning Optional title
+//	Body
+//	:::
+type admonition struct {
+	ast.Container
+
+	Kind  string
+	Title string
+}
+
+// githubAdmonitions are the kinds GitHub recognizes in blockquotes.
+var githubAdmonitions = map[string]bool{
+	"note": true, "tip": true, "important": true, "warning": true, "caution": true,
+}
+
+// quoteBreak separates blockquotes in a Markdown source; it is removed after
+// parsing.
+const quoteBreak = "<!-- go-static:quote-break -->"
+
+var (
+	admonitionMarker      = regexp.MustCompile(`^\[!([A-Za-z]+)\][ \t]*([^\n]*)\n?`)
+	admonitionFence       = regexp.MustCompile(`^(:{3,})[ \t]*([A-Za-z][\w-]*)[ \t]*(.*)$`)
+	admonitionPlaceholder = regexp.MustCompile(`^<!-- go-static:admonition:(\d+) -->`)
+	codeFencePattern      = regexp.MustCompile("^(`{3,}|~{3,})")
+)
+
+// fencedAdmonition is a :::kind block lifted out of a Markdown source before
+// parsing, because the parser would otherwise read the colons as a
+// definition list.
+type fencedAdmonition struct {
+	kind, title string
+	body        []byte
+}
+
+// extractFencedAdmonitions replaces every top-level :::kind block outside of
+// code blocks with a placeholder comment, and returns the blocks in order.
+// Fences with more colons can nest shorter ones.
+func extractFencedAdmonitions(source []byte) ([]byte, []fencedAdmonition) {
+	var out bytes.Buffer
+	var blocks []fencedAdmonition
+
+	lines := bytes.SplitAfter(source, []byte("\n"))
+	var code codeFenceTracker
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(string(line))
+
+		if code.inCode(trimmed) {
+			out.Write(line)
+			continue
+		}
+
+		m := admonitionFence.FindStringSubmatch(trimmed)
+		if m == nil {
+			out.Write(line)
+			continue
+		}
+
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(string(lines[j])) == m[1] {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			out.Write(line)
+			continue
+		}
+
+		fmt.Fprintf(&out, "\n<!-- go-static:admonition:%d -->\n\n", len(blocks))
+		blocks = append(blocks, fencedAdmonition{
+			kind:  strings.ToLower(m[2]),
+			title: strings.TrimSpace(m[3]),
+			body:  bytes.Join(lines[i+1:end], nil),
+		})
+		i = end
+	}
+
+	return out.Bytes(), blocks
+}
+
+// separateMarkedQuotes ends a blockquote that starts with a [!KIND] marker
+// at the first blank line. The parser joins blockquotes separated only by
+// blank lines, which would pull a following plain quote into the
+// admonition.
+func separateMarkedQuotes(source []byte) []byte {
+	var out bytes.Buffer
+	var code codeFenceTracker
+
+	marked, blank := false, false
+	for _, line := range bytes.SplitAfter(source, []byte("\n")) {
+		trimmed := strings.TrimSpace(string(line))
+		if code.inCode(trimmed) {
+			out.Write(line)
+			continue
+		}
+
+		quoted := strings.HasPrefix(trimmed, ">")
+		switch {
+		case quoted && blank && marked:
+			out.WriteString(quoteBreak + "\n\n")
+			marked = false
+		case !quoted && trimmed != "":
+			marked = false
+		}
+		if quoted && !marked {
+			marked = admonitionMarker.MatchString(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+		}
+		blank = trimmed == ""
+
+		out.Write(line)
+	}
+
+	return out.Bytes()
+}
+
+// codeFenceTracker follows fenced code blocks line by line.
+type codeFenceTracker struct {
+	fence string
+}
+
+// inCode reports whether the trimmed line opens, closes or is inside a
+// fenced code block.
+func (t *codeFenceTracker) inCode(trimmed string) bool {
+	if t.fence != "" {
+		if strings.HasPrefix(trimmed, t.fence) && strings.Trim(trimmed, t.fence[:1]) == "" {
+			t.fence = ""
+		}
+		return true
+	}
+	if fence := codeFencePattern.FindString(trimmed); fence != "" {
+		t.fence = fence
+		return true
+	}
+	return false
+}
+
+// insertFencedAdmonitions removes the breaks left by separateMarkedQuotes and
+// replaces the placeholders left by extractFencedAdmonitions with
+// admonitions whose bodies are parsed with parse.
+func insertFencedAdmonitions(doc ast.Node, blocks []fencedAdmonition, parse func([]byte) ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		block, ok := node.(*ast.HTMLBlock)
+		if !ok {
+			return ast.GoToNext
+		}
+		if string(block.Literal) == quoteBreak {
+			replace(node)
+			return ast.GoToNext
+		}
+		m := admonitionPlaceholder.FindSubmatch(block.Literal)
+		if m == nil {
+			return ast.GoToNext
+		}
+		n, _ := strconv.Atoi(string(m[1]))
+		if n >= len(blocks) {
+			return ast.GoToNext
+		}
+
+		fenced := blocks[n]
+		replacement := &admonition{Kind: fenced.kind, Title: fenced.title}
+		for _, child := range parse(fenced.body).GetChildren() {
+			adopt(replacement, child)
+		}
+		replace(node, replacement)
+		return ast.SkipChildren
+	})
+}
+
+// convertBlockquoteAdmonitions replaces blockquotes starting with a
+// [!KIND] marker with admonitions. Consecutive marked quotes that the parser
+// merged into one blockquote become separate admonitions.
+func convertBlockquoteAdmonitions(doc ast.Node) {
+	var quotes []*ast.BlockQuote
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if quote, ok := node.(*ast.BlockQuote); ok && entering && admonitionKind(quote.Children) != "" {
+			quotes = append(quotes, quote)
+		}
+		return ast.GoToNext
+	})
+
+	for _, quote := range quotes {
+		var replacements []ast.Node
+		var current *admonition
+		for _, child := range quote.Children {
+			if kind := admonitionKind([]ast.Node{child}); kind != "" {
+				current = &admonition{Kind: kind, Title: stripAdmonitionMarker(child)}
+				replacements = append(replacements, current)
+				if len(child.GetChildren()) == 0 {
+					continue
+				}
+			}
+			adopt(current, child)
+		}
+
+		replace(quote, replacements...)
+	}
+}
+
+// replace swaps node for replacements in its parent's children.
+func replace(node ast.Node, replacements ...ast.Node) {
+	parent := node.GetParent()
+	var children []ast.Node
+	for _, sibling := range parent.GetChildren() {
+		if sibling != node {
+			children = append(children, sibling)
+			continue
+		}
+		for _, replacement := range replacements {
+			replacement.SetParent(parent)
+			children = append(children, replacement)
+		}
+	}
+	parent.SetChildren(children)
+}
+
+// adopt moves child under parent. Unlike ast.AppendChild it keeps the
+// child's own children.
+func adopt(parent, child ast.Node) {
+	child.SetParent(parent)
+	parent.SetChildren(append(parent.GetChildren(), child))
+}
+
+// admonitionKind returns the kind named by a [!KIND] marker at the start of
+// the first of nodes, if it is a paragraph.
+func admonitionKind(nodes []ast.Node) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	para, ok := nodes[0].(*ast.Paragraph)
+	if !ok || len(para.Children) == 0 {
+		return ""
+	}
+	text, ok := para.Children[0].(*ast.Text)
+	if !ok {
+		return ""
+	}
+	m := admonitionMarker.FindSubmatch(text.Literal)
+	if m == nil || !githubAdmonitions[strings.ToLower(string(m[1]))] {
+		return ""
+	}
+	return strings.ToLower(string(m[1]))
+}
+
+// stripAdmonitionMarker removes the marker line from a paragraph and returns
+// the title that followed the marker. A paragraph left empty loses its
+// children.
+func stripAdmonitionMarker(node ast.Node) string {
+	para := node.(*ast.Paragraph)
+	text := para.Children[0].(*ast.Text)
+	m := admonitionMarker.FindSubmatch(text.Literal)
+
+	text.Literal = text.Literal[len(m[0]):]
+	if len(text.Literal) == 0 {
+		para.Children = para.Children[1:]
+	}
+	return strings.TrimSpace(string(m[2]))
+}
+
+func renderAdmonition(w io.Writer, node *admonition, entering bool) {
+	if !entering {
+		io.WriteString(w, "</aside>\n")
+		return
+	}
+
+	title := node.Title
+	if title == "" {
+		title = strings.ToUpper(node.Kind[:1]) + node.Kind[1:]
+	}
+	fmt.Fprintf(w, "<aside class=\"admonition admonition-%s\" role=\"note\">\n", html.EscapeString(node.Kind))
+	fmt.Fprintf(w, "<p class=\"admonition-title\">%s</p>\n", html.EscapeString(title))
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/ahoglund/go-static/pkg/config"
+	sitetemplate "github.com/ahoglund/go-static/pkg/template"
+)
+
+// commandConverter renders pages with an external command configured under
+// converters in config.yaml.
+type commandConverter struct {
+	p   *PageProcessor
+	cfg config.CommandConfig
+}
+
+func (c commandConverter) Convert(page *Page, source string) (string, error) {
+	out, err := c.p.runCommand(c.cfg, page, source)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", page.file, err)
+	}
+	return out, nil
+}
+
+// applyFilters passes content through the configured filters of the given
+// stage that apply to page, in order.
+func (p *PageProcessor) applyFilters(stage string, page *Page, content string) (string, error) {
+	for _, filter := range p.config.Filters {
+		if filter.Stage != stage || !filterMatches(filter, page) {
+			continue
+		}
+		out, err := p.runCommand(filter.CommandConfig, page, content)
+		if err != nil {
+			return "", fmt.Errorf("filter for %s: %w", page.OutputPath, err)
+		}
+		content = out
+	}
+	return content, nil
+}
+
+func filterMatches(filter config.FilterConfig, page *Page) bool {
+	if len(filter.Extensions) == 0 {
+		return true
+	}
+	for _, ext := range filter.Extensions {
+		if normalizeExt(ext) == strings.ToLower(path.Ext(page.Path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand runs an external converter or filter with input on stdin and
+// returns its stdout. The page's frontmatter is passed as JSON in
+// GO_STATIC_FRONTMATTER, its source path in GO_STATIC_PAGE and its permalink
+// in GO_STATIC_PERMALINK. Failures include the command's stderr.
+func (p *PageProcessor) runCommand(cfg config.CommandConfig, page *Page, input string) (string, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = config.DefaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	frontMatter, err := json.Marshal(sitetemplate.JSONValue(page.frontMatter))
+	if err != nil {
+		return "", fmt.Errorf("error encoding frontmatter: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, cfg.Command[0], cfg.Command[1:]...)
+	cmd.Dir = p.config.RootDir
+	cmd.Env = append(os.Environ(),
+		"GO_STATIC_FRONTMATTER="+string(frontMatter),
+		"GO_STATIC_PAGE="+page.Path,
+		"GO_STATIC_PERMALINK="+page.Permalink,
+	)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	name := strings.Join(cfg.Command, " ")
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("command %q timed out after %s%s", name, timeout, stderrSuffix(stderr))
+	}
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w%s", name, err, stderrSuffix(stderr))
+	}
+	return stdout.String(), nil
+}
+
+func stderrSuffix(stderr bytes.Buffer) string {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return ": " + msg
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Converter turns the body of a page source file, after its frontmatter, into
+// the HTML passed to the page's layout as .content.
+type Converter interface {
+	Convert(page *Page, source string) (string, error)
+}
+
+// FrontMatterReader is implemented by converters for formats that carry their
+// metadata in their own syntax instead of a YAML frontmatter block. It
+// returns the page's frontmatter and the body passed on to Convert.
+type FrontMatterReader interface {
+	ReadFrontMatter(source string) (frontMatter map[interface{}]interface{}, body string, err error)
+}
+
+// ConverterFunc adapts an ordinary function to the Converter interface.
+type ConverterFunc func(page *Page, source string) (string, error)
+
+func (f ConverterFunc) Convert(page *Page, source string) (string, error) {
+	return f(page, source)
+}
+
+// converters holds the converters registered with RegisterConverter, keyed
+// by file extension.
+var converters = map[string]Converter{}
+
+// RegisterConverter makes c the converter for page files with the extension
+// ext, such as ".adoc", in every PageProcessor created afterwards. It
+// replaces any built-in or previously registered converter for ext. It is
+// meant to be called during initialization and is not safe for concurrent use.
+func RegisterConverter(ext string, c Converter) {
+	converters[normalizeExt(ext)] = c
+}
+
+// RegisterConverter makes c the converter for page files with the extension
+// ext in this processor only.
+func (p *PageProcessor) RegisterConverter(ext string, c Converter) {
+	p.converters[normalizeExt(ext)] = c
+}
+
+// builtinConverters returns the converters for the formats go-static supports
+// out of the box.
+func (p *PageProcessor) builtinConverters() map[string]Converter {
+	return map[string]Converter{
+		".html": ConverterFunc(func(page *Page, source string) (string, error) {
+			return source, nil
+		}),
+		".md":       ConverterFunc(p.renderMarkdown),
+		".markdown": ConverterFunc(p.renderMarkdown),
+		".tmpl":     ConverterFunc(p.renderTemplatePage),
+		".org":      orgConverter{p},
+		".ipynb":    notebookConverter{p},
+	}
+}
+
+// converter returns the converter for file, or nil if file isn't a page.
+func (p *PageProcessor) converter(file string) Converter {
+	return p.converters[strings.ToLower(filepath.Ext(file))]
+}
+
+// isPageFile reports whether a converter is registered for file.
+func (p *PageProcessor) isPageFile(file string) bool {
+	return p.converter(file) != nil
+}
+
+// renderTemplatePage executes a .tmpl page with its frontmatter as data.
+func (p *PageProcessor) renderTemplatePage(page *Page, source string) (string, error) {
+	parsedTemplate := template.New(page.file).Funcs(p.templateFuncs())
+	if p.config.StrictTemplates {
+		parsedTemplate.Option("missingkey=error")
+	}
+	if _, err := parsedTemplate.Parse(source); err != nil {
+		return "", fmt.Errorf("error parsing template %s: %w", page.file, p.loader.Diagnose(err))
+	}
+
+	var buf bytes.Buffer
+	if err := parsedTemplate.Execute(&buf, page.frontMatter); err != nil {
+		return "", fmt.Errorf("error executing template %s: %w", page.file, p.loader.Diagnose(err))
+	}
+	return buf.String(), nil
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadData reads the YAML and JSON files of the data directories into one
+// tree, keyed by their paths without extension: data/team/members.yaml is
+// .Site.Data.team.members. A site's file replaces the theme file with the
+// same path.
+func (p *PageProcessor) loadData() (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	dirs := p.config.DataDirs()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		err := filepath.WalkDir(dir, func(file string, info fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(file)
+			if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+				return nil
+			}
+			if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+				return nil
+			}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			var value interface{}
+			if ext == ".json" {
+				err = json.Unmarshal(content, &value)
+			} else {
+				err = yaml.Unmarshal(content, &value)
+			}
+			if err != nil {
+				return fmt.Errorf("error parsing %s: %w", file, err)
+			}
+
+			relativePath, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			keys := strings.Split(strings.TrimSuffix(filepath.ToSlash(relativePath), ext), "/")
+			node := data
+			for _, key := range keys[:len(keys)-1] {
+				child, ok := node[key].(map[string]interface{})
+				if !ok {
+					child = map[string]interface{}{}
+					node[key] = child
+				}
+				node = child
+			}
+			node[keys[len(keys)-1]] = value
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load data: %w", err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,301 @@
+package processor
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ahoglund/go-static/pkg/config"
+	"github.com/ahoglund/go-static/pkg/epub"
+)
+
+// EPUBStylesheet is the file in the assets directories that replaces the
+// default stylesheet of exported books.
+const EPUBStylesheet = "epub.css"
+
+// EPUB packages the pages of a section, such as "handbook", or of the whole
+// site when section is "", as a book. Each page of the default language is
+// a chapter, with the same HTML content as on the site, in reading order:
+// a directory's index page, then its pages and subdirectories ordered by
+// weight, then date. Links between chapters stay in the book, other links
+// lead to the site, and the images the chapters show are included.
+func (p *PageProcessor) EPUB(section string) (*epub.Book, error) {
+	if p.site == nil {
+		if err := p.IndexPages(); err != nil {
+			return nil, err
+		}
+	}
+
+	root := strings.Trim(path.Clean("/"+filepath.ToSlash(section)), "/")
+	if root == "" {
+		root = "."
+	}
+	pages, levels := p.bookPages(root)
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages in %s", path.Join(p.config.PagesDir, root))
+	}
+
+	book := &epub.Book{
+		Title:      p.config.EPUB.Title,
+		Language:   p.site.defaultLanguage.Code,
+		Creator:    p.config.EPUB.Author,
+		Publisher:  p.config.EPUB.Publisher,
+		Rights:     p.config.EPUB.Rights,
+		Identifier: p.config.EPUB.Identifier,
+	}
+	index := p.site.bundle(root)
+	if index != nil && index.Language != p.site.defaultLanguage {
+		index = index.translation(p.site.defaultLanguage)
+	}
+	if book.Title == "" && index != nil {
+		book.Title = index.Title
+	}
+	if book.Title == "" {
+		book.Title = path.Base(root)
+	}
+	if index != nil {
+		book.Description, _ = index.frontMatter["description"].(string)
+	}
+	if book.Language == "" {
+		book.Language = p.config.DefaultLanguage
+	}
+	if book.Language == "" {
+		book.Language = "en"
+	}
+	if book.Identifier == "" {
+		book.Identifier = epub.NewIdentifier(p.config.BaseURL + " " + book.Title)
+	}
+	for _, page := range pages {
+		if page.Lastmod.After(book.Modified) {
+			book.Modified = page.Lastmod
+		}
+	}
+	if book.Modified.IsZero() {
+		book.Modified = time.Now()
+	}
+	for _, dir := range p.config.AssetsDirs() {
+		if css, err := os.ReadFile(filepath.Join(dir, EPUBStylesheet)); err == nil {
+			book.Stylesheet = string(css)
+			break
+		}
+	}
+
+	chapters := map[string]int{}
+	for i, page := range pages {
+		for _, format := range page.OutputFormats {
+			chapters[format.Permalink] = i
+		}
+	}
+	resources := map[string]string{}
+
+	for i, page := range pages {
+		content, err := p.renderContent(page)
+		if err != nil {
+			return nil, err
+		}
+		base, err := url.Parse(page.Permalink)
+		if err != nil {
+			return nil, err
+		}
+
+		var resourceErr error
+		body, err := epub.XHTML(content, func(element, attr, value string) (string, bool) {
+			u, err := url.Parse(value)
+			if err != nil || value == "" || strings.HasPrefix(value, "#") {
+				return value, true
+			}
+			if attr == "href" {
+				if u.Scheme != "" || u.Host != "" {
+					return value, true
+				}
+				target := base.ResolveReference(u)
+				if chapter, ok := chapters[target.Path]; ok {
+					link := path.Base(epub.ChapterName(chapter))
+					if target.Fragment != "" {
+						link += "#" + target.Fragment
+					}
+					return link, true
+				}
+				if link := p.config.AbsURL(target.String()); strings.Contains(link, "://") {
+					return link, true
+				}
+				return "", true
+			}
+
+			if u.Scheme == "" && u.Host == "" {
+				// Relative images are looked up next to the page's source
+				// too, where they are with pretty URLs.
+				rels := []string{strings.TrimPrefix(base.ResolveReference(u).Path, p.config.BasePath())}
+				if !strings.HasPrefix(u.Path, "/") {
+					rels = append(rels, path.Join(path.Dir(page.key), u.Path))
+				}
+				name, err := p.bookResource(book, resources, rels...)
+				if err != nil {
+					resourceErr = err
+					return "", false
+				}
+				if name != "" {
+					return path.Join("..", name), true
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %s: leaving out %s, which is not a file of the site\n", page.source(), value)
+			return "", false
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error converting %s to XHTML: %w", page.source(), err)
+		}
+		if resourceErr != nil {
+			return nil, resourceErr
+		}
+		book.Chapters = append(book.Chapters, epub.Chapter{Title: page.Title, Level: levels[i], Body: body})
+	}
+	return book, nil
+}
+
+// bookPages returns the pages of the default language under root, a
+// directory relative to PagesDir, in reading order, with their nesting
+// levels for the table of contents.
+func (p *PageProcessor) bookPages(root string) ([]*Page, []int) {
+	indexes := map[string]*Page{}
+	pagesIn := map[string][]*Page{}
+	subdirs := map[string][]string{}
+	for _, page := range p.site.Pages {
+		if page.file == "" || page.Language != p.site.defaultLanguage {
+			continue
+		}
+		if root != "." && !strings.HasPrefix(page.key, root+"/") {
+			continue
+		}
+
+		dir := path.Dir(page.key)
+		if isIndexPage(page) {
+			indexes[dir] = page
+		} else {
+			pagesIn[dir] = append(pagesIn[dir], page)
+		}
+		for d := dir; d != root && d != "."; d = path.Dir(d) {
+			parent := path.Dir(d)
+			known := false
+			for _, subdir := range subdirs[parent] {
+				known = known || subdir == d
+			}
+			if !known {
+				subdirs[parent] = append(subdirs[parent], d)
+			}
+		}
+	}
+
+	var pages []*Page
+	var levels []int
+	var walk func(dir string, level int)
+	walk = func(dir string, level int) {
+		if index := indexes[dir]; index != nil {
+			pages = append(pages, index)
+			levels = append(levels, level)
+			level++
+		}
+
+		// Subdirectories are ordered by their index pages, or after the
+		// pages by name when they have none.
+		type entry struct {
+			page *Page
+			dir  string
+		}
+		var entries []entry
+		for _, page := range pagesIn[dir] {
+			entries = append(entries, entry{page: page})
+		}
+		for _, subdir := range subdirs[dir] {
+			entries = append(entries, entry{page: indexes[subdir], dir: subdir})
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			switch {
+			case a.page != nil && b.page != nil:
+				return pageLess(a.page, b.page)
+			case a.page != nil || b.page != nil:
+				return a.page != nil
+			}
+			return a.dir < b.dir
+		})
+
+		for _, entry := range entries {
+			if entry.dir != "" {
+				walk(entry.dir, level)
+			} else {
+				pages = append(pages, entry.page)
+				levels = append(levels, level)
+			}
+		}
+	}
+	walk(root, 0)
+	return pages, levels
+}
+
+// renderContent converts a page's body to HTML and runs the content
+// filters, as for its layout.
+func (p *PageProcessor) renderContent(page *Page) (string, error) {
+	p.page = page
+	defer func() { p.page = nil }()
+
+	content, err := p.converter(page.file).Convert(page, page.content)
+	if err != nil {
+		return "", err
+	}
+	return p.applyFilters(config.FilterContent, page, content)
+}
+
+// bookResource adds the first file of the site at one of the paths, page
+// resources or assets relative to the site root, to the book's images and
+// returns its name in the book, or "" if there is no such file.
+func (p *PageProcessor) bookResource(book *epub.Book, resources map[string]string, paths ...string) (string, error) {
+	type candidate struct{ file, rel string }
+	var candidates []candidate
+	for _, rel := range paths {
+		if unescaped, err := url.PathUnescape(rel); err == nil {
+			rel = unescaped
+		}
+		rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+
+		candidates = append(candidates, candidate{filepath.Join(p.config.PagesDir, filepath.FromSlash(rel)), rel})
+		for _, language := range p.site.Languages {
+			if prefix := strings.TrimPrefix(language.Prefix, "/"); prefix != "" && strings.HasPrefix(rel, prefix+"/") {
+				key := strings.TrimPrefix(rel, prefix+"/")
+				candidates = append(candidates, candidate{filepath.Join(p.config.PagesDir, filepath.FromSlash(key)), key})
+			}
+		}
+		for _, dir := range p.config.AssetsDirs() {
+			candidates = append(candidates, candidate{filepath.Join(dir, filepath.FromSlash(rel)), rel})
+		}
+	}
+
+	for _, c := range candidates {
+		file, rel := c.file, c.rel
+		if name, ok := resources[file]; ok {
+			return name, nil
+		}
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() || p.isPageFile(file) {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		mediaType := mime.TypeByExtension(path.Ext(rel))
+		if mediaType == "" {
+			mediaType = "application/octet-stream"
+		}
+		name := path.Join("images", rel)
+		resources[file] = name
+		book.Resources = append(book.Resources, epub.Resource{Name: name, MediaType: mediaType, Data: data})
+		return name, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,486 @@
+package processor
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// geminiLink is a link collected from a block of text, written as a link
+// line after the block since gemtext has no inline links.
+type geminiLink struct {
+	URL   string
+	Label string
+}
+
+// writeGemini publishes every Markdown page as gemtext in GeminiDir, at the
+// path of its HTML file with a .gmi extension, along with index.gmi, which
+// lists the pages after the home page's content, and gemlog.gmi, a feed of
+// the dated pages in the Gemini subscription format. The page resources
+// and assets the pages link to are copied next to them.
+func (p *PageProcessor) writeGemini() error {
+	p.geminiFiles = map[string]string{}
+	defer func() { p.geminiFiles = nil }()
+
+	var pages []*Page
+	for _, page := range p.site.Pages {
+		if isMarkdownPage(page) {
+			pages = append(pages, page)
+		}
+	}
+
+	var home *Page
+	for _, page := range pages {
+		if pageKind(page) == KindHome && page.Language == p.site.defaultLanguage {
+			home = page
+			continue
+		}
+		gemtext, err := p.gemtext(page)
+		if err != nil {
+			return err
+		}
+		if err := p.writeGeminiFile(p.geminiPath(page), gemtext); err != nil {
+			return err
+		}
+	}
+
+	title := p.config.Gemini.Title
+	if title == "" && home != nil {
+		title = home.Title
+	}
+
+	var index strings.Builder
+	if home != nil {
+		gemtext, err := p.gemtext(home)
+		if err != nil {
+			return err
+		}
+		index.WriteString(gemtext)
+	} else {
+		fmt.Fprintf(&index, "# %s\n", title)
+	}
+	index.WriteString("\n## Pages\n\n")
+	index.WriteString("=> /gemlog.gmi Gemlog\n")
+	for _, page := range pages {
+		if page != home {
+			fmt.Fprintf(&index, "=> %s %s\n", p.geminiURL(page), page.Title)
+		}
+	}
+	if err := p.writeGeminiFile("index.gmi", index.String()); err != nil {
+		return err
+	}
+
+	var dated []*Page
+	for _, page := range pages {
+		if page != home && !pageDate(page).IsZero() {
+			dated = append(dated, page)
+		}
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		return pageDate(dated[i]).After(pageDate(dated[j]))
+	})
+
+	var gemlog strings.Builder
+	fmt.Fprintf(&gemlog, "# %s\n\n", title)
+	for _, page := range dated {
+		date := pageDate(page).Format("2006-01-02")
+		fmt.Fprintf(&gemlog, "=> %s %s - %s\n", p.geminiURL(page), date, page.Title)
+	}
+	if err := p.writeGeminiFile("gemlog.gmi", gemlog.String()); err != nil {
+		return err
+	}
+
+	for name, src := range p.geminiFiles {
+		dst := filepath.Join(p.config.GeminiDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return err
+		}
+		if err := copyFile(src, dst); err != nil {
+			return fmt.Errorf("error copying %s to the capsule: %w", src, err)
+		}
+	}
+	return nil
+}
+
+func (p *PageProcessor) writeGeminiFile(name, content string) error {
+	file := filepath.Join(p.config.GeminiDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		return fmt.Errorf("error writing gemtext: %w", err)
+	}
+	return nil
+}
+
+// geminiPath returns the file of a page in the capsule, relative to
+// GeminiDir: about.gmi, or about/index.gmi with pretty URLs.
+func (p *PageProcessor) geminiPath(page *Page) string {
+	slug, _ := page.frontMatter["slug"].(string)
+	outputPath, _ := p.permalink(page.key, slug, page.Language)
+	return strings.TrimSuffix(outputPath, path.Ext(outputPath)) + ".gmi"
+}
+
+// geminiURL returns the capsule path of a page, linking to the directory of
+// index.gmi files.
+func (p *PageProcessor) geminiURL(page *Page) string {
+	name := "/" + p.geminiPath(page)
+	if path.Base(name) == "index.gmi" {
+		return strings.TrimSuffix(name, "index.gmi")
+	}
+	return name
+}
+
+// gemtext converts a Markdown page to gemtext. Headings, lists, quotes and
+// preformatted blocks map to their gemtext lines, and the links in each
+// block become link lines after it, as geminiLinkURL maps them.
+func (p *PageProcessor) gemtext(page *Page) (string, error) {
+	source, err := p.expandShortcodes(&shortcodeContext{page: page, file: page.file}, page.content)
+	if err != nil {
+		return "", err
+	}
+
+	w := &gemtextWriter{p: p, page: page}
+	doc := parseMarkdown([]byte(source))
+	if heading, ok := ast.GetFirstChild(doc).(*ast.Heading); !ok || heading.Level != 1 {
+		w.line("# " + page.Title)
+		w.end()
+	}
+	w.blocks(doc)
+	return strings.TrimSpace(w.b.String()) + "\n", nil
+}
+
+type gemtextWriter struct {
+	p     *PageProcessor
+	page  *Page
+	b     strings.Builder
+	links []geminiLink
+	// blank is set after a blank line, so blocks are separated by one.
+	blank bool
+}
+
+func (w *gemtextWriter) line(s string) {
+	w.b.WriteString(s)
+	w.b.WriteString("\n")
+	w.blank = false
+}
+
+// end closes a block with its link lines and a blank line.
+func (w *gemtextWriter) end() {
+	for _, link := range w.links {
+		if link.Label != "" && link.Label != link.URL {
+			w.line("=> " + link.URL + " " + link.Label)
+		} else {
+			w.line("=> " + link.URL)
+		}
+	}
+	w.links = nil
+	if !w.blank {
+		w.b.WriteString("\n")
+		w.blank = true
+	}
+}
+
+func (w *gemtextWriter) blocks(parent ast.Node) {
+	for _, node := range parent.GetChildren() {
+		w.block(node)
+	}
+}
+
+func (w *gemtextWriter) block(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Heading:
+		level := node.Level
+		if level > 3 {
+			level = 3
+		}
+		w.line(strings.Repeat("#", level) + " " + w.inline(node))
+		w.end()
+	case *ast.Paragraph:
+		if text := w.inline(node); text != "" {
+			w.line(text)
+		}
+		w.end()
+	case *ast.List:
+		w.list(node)
+		w.end()
+	case *ast.CodeBlock:
+		w.preformatted(strings.TrimSpace(string(node.Info)), string(node.Literal))
+	case *ast.MathBlock:
+		w.preformatted("math", string(node.Literal))
+	case *ast.Table:
+		w.preformatted("table", w.table(node))
+	case *ast.BlockQuote:
+		w.quote("", node)
+	case *admonition:
+		title := node.Title
+		if title == "" && node.Kind != "" {
+			title = strings.ToUpper(node.Kind[:1]) + node.Kind[1:]
+		}
+		w.quote(title, node)
+	case *ast.HTMLBlock, *ast.HorizontalRule:
+	default:
+		w.blocks(node)
+	}
+}
+
+// list writes each item as a bullet line, nested lists included and the
+// items of ordered lists numbered, since gemtext has only one level of
+// unordered lists.
+func (w *gemtextWriter) list(list *ast.List) {
+	number := list.Start
+	if number == 0 {
+		number = 1
+	}
+	for _, item := range list.GetChildren() {
+		var text []string
+		for _, child := range item.GetChildren() {
+			if nested, ok := child.(*ast.List); ok {
+				if len(text) > 0 {
+					w.item(list, number, strings.Join(text, " "))
+					text = nil
+				}
+				w.list(nested)
+				continue
+			}
+			if t := w.inline(child); t != "" {
+				text = append(text, t)
+			}
+		}
+		if len(text) > 0 {
+			w.item(list, number, strings.Join(text, " "))
+		}
+		number++
+	}
+}
+
+func (w *gemtextWriter) item(list *ast.List, number int, text string) {
+	if list.ListFlags&ast.ListTypeOrdered != 0 {
+		text = strconv.Itoa(number) + ". " + text
+	}
+	w.line("* " + text)
+}
+
+func (w *gemtextWriter) preformatted(alt, text string) {
+	w.end()
+	w.line("```" + alt)
+	w.b.WriteString(strings.TrimSuffix(text, "\n"))
+	w.line("")
+	w.line("```")
+	w.end()
+}
+
+// quote writes the text of a block quote or admonition as quote lines,
+// with the links in it after them.
+func (w *gemtextWriter) quote(title string, node ast.Node) {
+	quoted := &gemtextWriter{p: w.p, page: w.page}
+	quoted.blocks(node)
+	var links []geminiLink
+	for _, line := range strings.Split(strings.TrimSpace(quoted.b.String()), "\n") {
+		if strings.HasPrefix(line, "=> ") {
+			parts := strings.SplitN(strings.TrimPrefix(line, "=> "), " ", 2)
+			link := geminiLink{URL: parts[0]}
+			if len(parts) == 2 {
+				link.Label = parts[1]
+			}
+			links = append(links, link)
+			continue
+		}
+		if title != "" {
+			w.line("> " + title)
+			title = ""
+		}
+		if line = strings.TrimSpace(line); line != "" && line != "```" {
+			w.line("> " + line)
+		}
+	}
+	w.links = append(w.links, links...)
+	w.end()
+}
+
+func (w *gemtextWriter) table(table *ast.Table) string {
+	var rows []string
+	ast.WalkFunc(table, func(node ast.Node, entering bool) ast.WalkStatus {
+		row, ok := node.(*ast.TableRow)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		var cells []string
+		for _, cell := range row.GetChildren() {
+			cells = append(cells, w.inline(cell))
+		}
+		rows = append(rows, strings.Join(cells, " | "))
+		return ast.SkipChildren
+	})
+	return strings.Join(rows, "\n")
+}
+
+// inline returns the text of node's inline children on one line, adding
+// their links and images to the block's links.
+func (w *gemtextWriter) inline(node ast.Node) string {
+	var b strings.Builder
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Text:
+			b.WriteString(w.wikiLinks(string(node.Literal)))
+		case *ast.Code:
+			b.WriteString("`" + string(node.Literal) + "`")
+		case *ast.Math:
+			b.WriteString(string(node.Literal))
+		case *ast.Softbreak, *ast.Hardbreak:
+			b.WriteString(" ")
+		case *ast.HTMLSpan:
+		case *ast.Link:
+			start := b.Len()
+			for _, child := range node.GetChildren() {
+				walk(child)
+			}
+			if link := w.p.geminiLinkURL(w.page, string(node.Destination)); link != "" {
+				w.links = append(w.links, geminiLink{URL: link, Label: strings.TrimSpace(b.String()[start:])})
+			}
+		case *ast.Image:
+			var alt strings.Builder
+			for _, child := range node.GetChildren() {
+				if text, ok := child.(*ast.Text); ok {
+					alt.Write(text.Literal)
+				}
+			}
+			label := alt.String()
+			if label == "" {
+				label = path.Base(string(node.Destination))
+			}
+			if link := w.p.geminiLinkURL(w.page, string(node.Destination)); link != "" {
+				w.links = append(w.links, geminiLink{URL: link, Label: "Image: " + label})
+			}
+		default:
+			if leaf := node.AsLeaf(); leaf != nil && len(node.GetChildren()) == 0 {
+				b.Write(leaf.Literal)
+			}
+			for _, child := range node.GetChildren() {
+				walk(child)
+			}
+		}
+	}
+	for _, child := range node.GetChildren() {
+		walk(child)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// wikiLinks replaces the [[wiki links]] in text with their labels, adding
+// a link for each one that resolves. Unresolved links were already reported
+// when the HTML was rendered.
+func (w *gemtextWriter) wikiLinks(text string) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := wikiLinkPattern.FindStringSubmatch(match)
+		label := m[1]
+		if m[2] != "" {
+			label = m[2]
+		}
+		linked := w.p.site.resolveWikiLink(m[1])
+		if translation := linked.translation(w.page.Language); translation != nil {
+			linked = translation
+		}
+		if linked != nil && isMarkdownPage(linked) {
+			w.links = append(w.links, geminiLink{URL: w.p.geminiURL(linked), Label: label})
+		}
+		return label
+	})
+}
+
+// geminiLinkURL maps a link in a page to the capsule: links to Markdown
+// pages, by source path or permalink, lead to their gemtext, and links to
+// page resources and assets to copies in the capsule. Other site-relative
+// links lead to the web site when baseURL has a host and are left out,
+// returning "", when it doesn't. Absolute URLs into the site, such as the
+// output of ref, are mapped like site-relative ones; other absolute URLs
+// stay as they are.
+func (p *PageProcessor) geminiLinkURL(from *Page, dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+	if u.Scheme != "" || u.Host != "" {
+		site, err := url.Parse(p.config.BaseURL)
+		if err != nil || site.Host == "" || u.Host != site.Host ||
+			!strings.HasPrefix(u.Path+"/", p.config.BasePath()+"/") {
+			return dest
+		}
+		u.Scheme, u.Host, u.User = "", "", nil
+		dest = u.String()
+	}
+
+	target := p.linkedPage(from, dest)
+	if target == nil && u.Path != "" {
+		for _, page := range p.site.Pages {
+			if page.Permalink == u.Path {
+				target = page
+				break
+			}
+		}
+	}
+	if target != nil && isMarkdownPage(target) {
+		return p.geminiURL(target)
+	}
+
+	base, err := url.Parse(from.Permalink)
+	if err != nil {
+		return dest
+	}
+	resolved := base.ResolveReference(u)
+	if name := p.geminiFile(resolved.Path); name != "" {
+		return "/" + name
+	}
+	if u.Path != "" && !strings.HasPrefix(u.Path, "/") {
+		// Relative files are looked up next to the page's source too,
+		// where they are with pretty URLs.
+		if name := p.geminiFile(path.Join(p.config.BasePath(), "/", path.Dir(from.Path), u.Path)); name != "" {
+			return "/" + name
+		}
+	}
+	if link := p.config.AbsURL(resolved.String()); strings.Contains(link, "://") {
+		return link
+	}
+	if u.Path == "" {
+		// Gemtext has no anchors to link to within a page.
+		return ""
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s: leaving out the link to %s, which is not in the capsule, since baseURL has no host\n", from.source(), dest)
+	return ""
+}
+
+// geminiFile finds the page resource or asset published at a site-relative
+// path and adds it to the files copied to the capsule, returning its name
+// there, or "" if the path isn't such a file.
+func (p *PageProcessor) geminiFile(sitePath string) string {
+	rel := strings.TrimPrefix(sitePath, p.config.BasePath())
+	if unescaped, err := url.PathUnescape(rel); err == nil {
+		rel = unescaped
+	}
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return ""
+	}
+
+	dirs := append([]string{p.config.PagesDir}, p.config.AssetsDirs()...)
+	for _, dir := range dirs {
+		file := filepath.Join(dir, filepath.FromSlash(rel))
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() || p.isPageFile(file) {
+			continue
+		}
+		if p.geminiFiles != nil {
+			p.geminiFiles[rel] = file
+		}
+		return rel
+	}
+	return ""
+}
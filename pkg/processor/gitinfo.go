@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitInfo describes the last commit that changed a page's source file.
+type GitInfo struct {
+	Hash            string
+	AbbreviatedHash string
+	Subject         string
+	AuthorName      string
+	AuthorEmail     string
+	AuthorDate      time.Time
+}
+
+// gitLogFormat separates commits with a record separator and their fields
+// with a unit separator, so subjects and names can't be confused with the
+// file names that follow each commit.
+const gitLogFormat = "%x1e%H%x1f%h%x1f%s%x1f%an%x1f%ae%x1f%aI"
+
+// loadGitInfo reads the history of PagesDir from the local git repository
+// with a single git log and returns the last commit of every file, keyed by
+// absolute path. Files that were never committed have no entry.
+func (p *PageProcessor) loadGitInfo() (map[string]*GitInfo, error) {
+	pagesDir, err := filepath.Abs(p.config.PagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	toplevel, err := git(pagesDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(toplevel))
+
+	out, err := git(pagesDir, "-c", "core.quotePath=false", "log", "--name-only", "--no-renames", "--format="+gitLogFormat, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	infos := map[string]*GitInfo{}
+	var current *GitInfo
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\x1e") {
+			fields := strings.Split(line[1:], "\x1f")
+			if len(fields) != 6 {
+				return nil, fmt.Errorf("unexpected git log output %q", line)
+			}
+			current = &GitInfo{
+				Hash:            fields[0],
+				AbbreviatedHash: fields[1],
+				Subject:         fields[2],
+				AuthorName:      fields[3],
+				AuthorEmail:     fields[4],
+			}
+			current.AuthorDate, _ = time.Parse(time.RFC3339, fields[5])
+			continue
+		}
+		if line == "" || current == nil {
+			continue
+		}
+		file := filepath.Join(root, filepath.FromSlash(line))
+		if _, ok := infos[file]; !ok {
+			infos[file] = current
+		}
+	}
+	return infos, scanner.Err()
+}
+
+// git runs a git command in dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// pageGitInfo returns the last commit of a page's source file, or nil.
+func (s *Site) pageGitInfo(file string) *GitInfo {
+	if s.gitInfo == nil {
+		return nil
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil
+	}
+	// git reports paths below the repository's real location.
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	return s.gitInfo[abs]
+}
+
+// indexGitInfo loads the git history for the site when it is enabled. A
+// site outside a git repository, or without git installed, builds with a
+// warning and without git info.
+func (p *PageProcessor) indexGitInfo(site *Site) {
+	if !p.config.GitInfo.Enabled {
+		return
+	}
+	infos, err := p.loadGitInfo()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: git info unavailable: %v\n", err)
+		return
+	}
+	site.gitInfo = infos
+}
+
+// editURL returns the link to edit a source file: config's editURL followed
+// by the file's path relative to the site root.
+func (p *PageProcessor) editURL(file string) string {
+	relativePath, err := filepath.Rel(p.config.RootDir, file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(p.config.EditURL, "/") + "/" + (&url.URL{Path: filepath.ToSlash(relativePath)}).EscapedPath()
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadTranslations reads the translation strings of every language from
+// I18nDir/<code>.yaml, or from defaultLanguage's file, "en" by default, on a
+// site without configured languages. Strings missing there are read from
+// the themes' i18n directories. Each file maps an ID to a string, or to
+// "one" and "other" strings chosen by a count:
+//
+//	readMore: Weiterlesen
+//	posts:
+//	  one: "{{ .Count }} Beitrag"
+//	  other: "{{ .Count }} Beiträge"
+func (p *PageProcessor) loadTranslations(languages []*Language) error {
+	p.translations = map[string]map[string]interface{}{}
+	for _, language := range languages {
+		code := language.Code
+		if code == "" {
+			code = p.config.DefaultLanguage
+			if code == "" {
+				code = "en"
+			}
+		}
+		// Themes come first so the site's strings replace theirs.
+		translations := map[string]interface{}{}
+		dirs := p.config.I18nDirs()
+		for i := len(dirs) - 1; i >= 0; i-- {
+			for _, ext := range []string{".yaml", ".yml"} {
+				file := filepath.Join(dirs[i], code+ext)
+				content, err := os.ReadFile(file)
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", file, err)
+				}
+
+				var values map[string]interface{}
+				if err := yaml.Unmarshal(content, &values); err != nil {
+					return fmt.Errorf("error parsing YAML in %s: %w", file, err)
+				}
+				for id, value := range values {
+					translations[id] = value
+				}
+				break
+			}
+		}
+		p.translations[language.Code] = translations
+	}
+	return nil
+}
+
+// translate implements the T template function: it returns the string with
+// the given ID in the language of the page being rendered, falling back to
+// the default language and then to the ID itself. An optional argument is
+// the data for strings containing template actions; an integer argument is
+// also the count that chooses between "one" and "other" forms, available as
+// .Count.
+func (p *PageProcessor) translate(id string, args ...interface{}) (string, error) {
+	var data interface{}
+	count, counted := 0, false
+	if len(args) > 0 {
+		data = args[0]
+		if n, ok := data.(int); ok {
+			count, counted = n, true
+			data = map[string]interface{}{"Count": n}
+		}
+	}
+
+	language := p.site.defaultLanguage
+	if p.page != nil {
+		language = p.page.Language
+	}
+	value, ok := p.translations[language.Code][id]
+	if !ok {
+		value, ok = p.translations[p.site.defaultLanguage.Code][id]
+	}
+	if !ok {
+		key := language.Code + "\x00" + id
+		if !p.missingTranslations[key] {
+			p.missingTranslations[key] = true
+			fmt.Fprintf(os.Stderr, "Warning: missing translation %q for language %q\n", id, language.Code)
+		}
+		return id, nil
+	}
+
+	text := fmt.Sprint(value)
+	if forms, ok := value.(map[string]interface{}); ok {
+		form := "other"
+		if counted && count == 1 {
+			form = "one"
+		}
+		text = fmt.Sprint(forms[form])
+	}
+
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(id).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("translation %q: %w", id, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("translation %q: %w", id, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	sitetext "github.com/ahoglund/go-static/pkg/text"
+)
+
+// codeLanguages maps file extensions to the language named on included code
+// blocks, where the two differ.
+var codeLanguages = map[string]string{
+	".js": "javascript", ".ts": "typescript", ".py": "python", ".rb": "ruby",
+	".sh": "bash", ".yml": "yaml", ".rs": "rust", ".h": "c", ".hpp": "cpp",
+	".cc": "cpp", ".kt": "kotlin", ".md": "markdown", ".tmpl": "go-html-template",
+}
+
+var (
+	regionStart = regexp.MustCompile(`(?:^|\W)(?:#?region\s+|tag::)([\w-]+)`)
+	regionEnd   = regexp.MustCompile(`(?:^|\W)(?:#?endregion\s+|end::)([\w-]+)`)
+)
+
+// include embeds another file into a Markdown page:
+//
+//	{{< include "partials/intro.md" >}}
+//	{{< include "../cmd/main.go" lines="10-24" >}}
+//	{{< include "examples/client.py" region="connect" lang="python" >}}
+//
+// Markdown files are inlined, with their frontmatter removed and their own
+// shortcodes expanded. Any other file, or a Markdown file with code="true",
+// becomes a fenced code block. Paths starting with ./ or ../ are relative to
+// the including file; other paths are looked up in PartialsDir, then
+// PagesDir, then the site root. Files outside the site root can't be
+// included.
+func (p *PageProcessor) include(ctx *shortcodeContext, args []string, params map[string]string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("include expects one file argument")
+	}
+
+	file, err := p.includeFile(ctx, args[0])
+	if err != nil {
+		return "", err
+	}
+
+	chain := append(append([]string{}, ctx.includes...), ctx.file)
+	for _, included := range chain {
+		if included == file {
+			return "", fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), file)
+		}
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	if region := params["region"]; region != "" {
+		if text, err = extractRegion(text, region); err != nil {
+			return "", fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	if lines := params["lines"]; lines != "" {
+		if text, err = extractLines(text, lines); err != nil {
+			return "", fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	if ext := strings.ToLower(filepath.Ext(file)); (ext == ".md" || ext == ".markdown") && params["code"] != "true" {
+		if data := strings.SplitN(text, FrontMatterDelimiter, 3); len(data) == 3 && data[0] == "" {
+			text = data[2]
+		}
+		return p.expandShortcodes(&shortcodeContext{page: ctx.page, file: file, includes: chain}, text)
+	}
+
+	lang := params["lang"]
+	if lang == "" {
+		ext := strings.ToLower(filepath.Ext(file))
+		if lang = codeLanguages[ext]; lang == "" {
+			lang = strings.TrimPrefix(ext, ".")
+		}
+	}
+	return codeBlock(text, lang), nil
+}
+
+// includeFile resolves the path of an include to a file in the site.
+func (p *PageProcessor) includeFile(ctx *shortcodeContext, name string) (string, error) {
+	var candidates []string
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		candidates = []string{filepath.Join(filepath.Dir(ctx.file), filepath.FromSlash(name))}
+	} else {
+		for _, dir := range []string{p.config.PartialsDir, p.config.PagesDir, p.config.RootDir} {
+			candidates = append(candidates, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+	}
+
+	root, err := filepath.Abs(p.config.RootDir)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range candidates {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return "", err
+		}
+		if rel, err := filepath.Rel(root, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("include %q: outside of the site", name)
+		}
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("include %q: no such file in %s, %s or the site root", name, p.config.PartialsDir, p.config.PagesDir)
+}
+
+// extractRegion returns the lines between the markers of a named region,
+// such as "// region setup" and "// endregion setup", or Asciidoc-style
+// "tag::setup[]" and "end::setup[]", with region markers removed.
+func extractRegion(text, name string) (string, error) {
+	var out []string
+	inside, found := false, false
+	for _, line := range strings.Split(text, "\n") {
+		if m := regionEnd.FindStringSubmatch(line); m != nil {
+			if m[1] == name {
+				inside = false
+			}
+			continue
+		}
+		if m := regionStart.FindStringSubmatch(line); m != nil {
+			if m[1] == name {
+				inside, found = true, true
+			}
+			continue
+		}
+		if inside {
+			out = append(out, line)
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("region %q not found", name)
+	}
+	return sitetext.Dedent(strings.Join(out, "\n")), nil
+}
+
+// extractLines returns a 1-based inclusive line range such as "10-24",
+// "10-", "-24" or "7".
+func extractLines(text, spec string) (string, error) {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	from, to := spec, spec
+	if i := strings.Index(spec, "-"); i >= 0 {
+		from, to = spec[:i], spec[i+1:]
+	}
+	start, end := 1, len(lines)
+	var err error
+	if from != "" {
+		if start, err = strconv.Atoi(from); err != nil {
+			return "", fmt.Errorf("invalid line range %q", spec)
+		}
+	}
+	if to != "" {
+		if end, err = strconv.Atoi(to); err != nil {
+			return "", fmt.Errorf("invalid line range %q", spec)
+		}
+	}
+	if start < 1 || end > len(lines) || start > end {
+		return "", fmt.Errorf("line range %q outside of 1-%d", spec, len(lines))
+	}
+
+	return strings.Join(lines[start-1:end], "\n"), nil
+}
+
+// codeBlock fences text, using a fence longer than any backtick run in it.
+func codeBlock(text, lang string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimSuffix(text, "\n") + "\n" + fence + "\n"
+}
@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"path"
+	"strings"
+
+	sitetemplate "github.com/ahoglund/go-static/pkg/template"
+)
+
+// TemplateUsage describes a template of the site: where it is defined,
+// whether another definition overrides it, and which pages use it.
+type TemplateUsage struct {
+	sitetemplate.Definition
+	// Layout is the layout or base template file the definition belongs
+	// to, for blocks and for the templates of such files, or "".
+	Layout string
+	// OverriddenBy is the later definition with the same name that replaces
+	// this one, or nil.
+	OverriddenBy *sitetemplate.Definition
+	// Pages are the pages rendered with the template as their layout, or as
+	// the base of their layout.
+	Pages []*Page
+	// Used is set when rendering some page reaches the template.
+	Used bool
+}
+
+// InspectTemplates returns every template defined in the site's templates
+// and its themes', in parse order, with the pages that use each.
+//
+// The blocks defined in layout files, such as {{define "main"}} in
+// _default/single.tmpl, only apply while rendering that layout in its base
+// template, so they don't override each other. Other definitions share one
+// namespace, in which a later definition silently replaces an earlier one.
+func (p *PageProcessor) InspectTemplates() ([]*TemplateUsage, error) {
+	if p.site == nil {
+		if err := p.IndexPages(); err != nil {
+			return nil, err
+		}
+	}
+
+	definitions, err := p.loader.Definitions()
+	if err != nil {
+		return nil, err
+	}
+
+	layoutPages := map[string][]*Page{}
+	basePages := map[string][]*Page{}
+	for _, page := range p.site.Pages {
+		for _, format := range page.OutputFormats {
+			name, _ := p.pageTemplate(page, format.Name)
+			if name == "" {
+				continue
+			}
+			layoutPages[name] = append(layoutPages[name], page)
+			if base := p.baseTemplate(name); base != "" {
+				basePages[base] = append(basePages[base], page)
+			}
+		}
+	}
+
+	var usages []*TemplateUsage
+	global := map[string]*TemplateUsage{}
+	scoped := map[string]map[string]*TemplateUsage{}
+	byFile := map[string]*TemplateUsage{}
+	for i := range definitions {
+		usage := &TemplateUsage{Definition: definitions[i]}
+		usages = append(usages, usage)
+		file := usage.File.Name
+
+		layout := isLayoutFile(file, layoutPages, basePages)
+		if layout {
+			usage.Layout = file
+		}
+
+		switch {
+		case usage.IsFile:
+			byFile[file] = usage
+			usage.Pages = append(append([]*Page{}, layoutPages[file]...), basePages[file]...)
+		case layout:
+			if scoped[file] == nil {
+				scoped[file] = map[string]*TemplateUsage{}
+			}
+			scoped[file][usage.Name] = usage
+		default:
+			if previous, ok := global[usage.Name]; ok {
+				previous.OverriddenBy = &usage.Definition
+			}
+			global[usage.Name] = usage
+			usage.Pages = layoutPages[usage.Name]
+		}
+	}
+
+	// Mark what rendering each page reaches, starting at its layout and
+	// base, resolving calls in the layout's blocks before the namespace.
+	var mark func(usage *TemplateUsage, blocks map[string]*TemplateUsage, seen map[*TemplateUsage]bool)
+	mark = func(usage *TemplateUsage, blocks map[string]*TemplateUsage, seen map[*TemplateUsage]bool) {
+		if usage == nil || seen[usage] {
+			return
+		}
+		seen[usage] = true
+		usage.Used = true
+		for _, name := range usage.Calls {
+			if block, ok := blocks[name]; ok {
+				mark(block, blocks, seen)
+			} else {
+				mark(global[name], blocks, seen)
+			}
+		}
+	}
+	for name := range layoutPages {
+		seen := map[*TemplateUsage]bool{}
+		base := p.baseTemplate(name)
+		if base == "" {
+			if usage, ok := byFile[name]; ok {
+				mark(usage, scoped[name], seen)
+			} else {
+				mark(global[name], nil, seen)
+			}
+			continue
+		}
+
+		blocks := map[string]*TemplateUsage{}
+		for blockName, usage := range scoped[base] {
+			blocks[blockName] = usage
+		}
+		for blockName, usage := range scoped[name] {
+			blocks[blockName] = usage
+		}
+		mark(byFile[base], blocks, seen)
+		mark(byFile[name], blocks, seen)
+		for _, usage := range scoped[name] {
+			mark(usage, blocks, seen)
+		}
+	}
+
+	return usages, nil
+}
+
+// isLayoutFile reports whether a template file is a layout or base
+// template, whose definitions are blocks scoped to it: a file named after a
+// layout of the lookup order, in any output format, or used by a page as
+// its layout.
+func isLayoutFile(file string, layoutPages, basePages map[string][]*Page) bool {
+	name := strings.TrimSuffix(path.Base(file), ".tmpl")
+	switch strings.TrimSuffix(name, path.Ext(name)) {
+	case "single", "list", "terms", "baseof":
+		return true
+	}
+	return len(layoutPages[file]) > 0 || len(basePages[file]) > 0
+}
@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Language is one of the languages a site is published in.
+type Language struct {
+	// Code is the language code, e.g. "de", or "" for a site without
+	// configured languages.
+	Code   string
+	Name   string
+	Weight int
+	// Prefix is the URL path and output subdirectory of the language's
+	// pages, e.g. "/de", or "" for the site root.
+	Prefix string
+}
+
+// languages returns the configured languages, ordered by weight then code,
+// and the default language. A site without languages gets a single
+// language with an empty code.
+func (p *PageProcessor) languages() ([]*Language, *Language) {
+	if len(p.config.Languages) == 0 {
+		language := &Language{}
+		return []*Language{language}, language
+	}
+
+	var languages []*Language
+	for code, c := range p.config.Languages {
+		languages = append(languages, &Language{Code: code, Name: c.Name, Weight: c.Weight})
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].Weight != languages[j].Weight {
+			return languages[i].Weight < languages[j].Weight
+		}
+		return languages[i].Code < languages[j].Code
+	})
+
+	defaultLanguage := languages[0]
+	for _, language := range languages {
+		if language.Code == p.config.DefaultLanguage {
+			defaultLanguage = language
+		}
+	}
+
+	for _, language := range languages {
+		if basePath := p.config.Languages[language.Code].BasePath; basePath != nil {
+			language.Prefix = strings.TrimSuffix("/"+strings.Trim(*basePath, "/"), "/")
+		} else if language != defaultLanguage || p.config.DefaultLanguageInSubdir {
+			language.Prefix = "/" + language.Code
+		}
+	}
+
+	return languages, defaultLanguage
+}
+
+// language returns the language with the given code, or nil.
+func (s *Site) language(code string) *Language {
+	for _, language := range s.Languages {
+		if language.Code == code {
+			return language
+		}
+	}
+	return nil
+}
+
+// splitLanguage finds the language of a file under PagesDir, from a
+// top-level directory named after the language ("de/about.md") or a
+// language suffix ("about.de.md"). It returns the language and the path of
+// the file without that directory or suffix, which translations share.
+func (s *Site) splitLanguage(sourcePath string) (*Language, string) {
+	if i := strings.Index(sourcePath, "/"); i > 0 {
+		if language := s.language(sourcePath[:i]); language != nil && language.Code != "" {
+			return language, sourcePath[i+1:]
+		}
+	}
+
+	ext := path.Ext(sourcePath)
+	name := strings.TrimSuffix(sourcePath, ext)
+	if suffix := path.Ext(name); suffix != "" {
+		if language := s.language(suffix[1:]); language != nil && language.Code != "" {
+			return language, strings.TrimSuffix(name, suffix) + ext
+		}
+	}
+
+	return s.defaultLanguage, sourcePath
+}
+
+// languageSite returns the view of the site in one language: its pages,
+// menus and language.
+func (s *Site) languageSite(language *Language) *Site {
+	if view, ok := s.languageSites[language]; ok {
+		return view
+	}
+	return s
+}
+
+// indexLanguages splits the site into one view per language and links each
+// page to its translations, the pages of other languages with the same
+// path.
+func (s *Site) indexLanguages() {
+	s.languageSites = map[*Language]*Site{}
+	for _, language := range s.Languages {
+		view := &Site{
+			Language:        language,
+			Languages:       s.Languages,
+			Data:            s.Data,
+			byPath:          s.byPath,
+			stubs:           s.stubs,
+			defaultLanguage: s.defaultLanguage,
+		}
+		for _, page := range s.Pages {
+			if page.Language == language {
+				view.Pages = append(view.Pages, page)
+			}
+		}
+		s.languageSites[language] = view
+	}
+
+	byKey := map[string][]*Page{}
+	for _, page := range s.Pages {
+		byKey[page.key] = append(byKey[page.key], page)
+	}
+	for _, pages := range byKey {
+		sort.SliceStable(pages, func(i, j int) bool {
+			return languageLess(s.Languages, pages[i].Language, pages[j].Language)
+		})
+		for _, page := range pages {
+			for _, other := range pages {
+				if other != page && other.Language != page.Language {
+					page.Translations = append(page.Translations, other)
+				}
+			}
+		}
+	}
+}
+
+// translation returns the version of page in the given language, or nil.
+// It is nil-safe.
+func (page *Page) translation(language *Language) *Page {
+	if page == nil || page.Language == language {
+		return page
+	}
+	for _, translation := range page.Translations {
+		if translation.Language == language {
+			return translation
+		}
+	}
+	return nil
+}
+
+func languageLess(languages []*Language, a, b *Language) bool {
+	for _, language := range languages {
+		if language == a {
+			return a != b
+		}
+		if language == b {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/ahoglund/go-static/pkg/config"
+	sitetemplate "github.com/ahoglund/go-static/pkg/template"
+)
+
+// Page kinds, which choose the layouts a page is looked up in.
+const (
+	KindHome     = "home"
+	KindSection  = "section"
+	KindTaxonomy = "taxonomy"
+	KindPage     = "page"
+)
+
+// pageKind returns the kind of a page: the site's index page is the home
+// page, the index page of a directory with other pages is a section, and a
+// page can declare itself a taxonomy, such as a list of tags, with kind
+// frontmatter. Other index pages are leaf bundles, and pages like the rest.
+func pageKind(page *Page) string {
+	if kind, _ := page.frontMatter["kind"].(string); kind == KindTaxonomy {
+		return KindTaxonomy
+	}
+	if isIndexPage(page) {
+		if path.Dir(page.key) == "." {
+			return KindHome
+		}
+		if page.section {
+			return KindSection
+		}
+	}
+	return KindPage
+}
+
+// pageType returns the type frontmatter of a page, or its section: the top
+// directory it is in, or "" for pages at the site root.
+func pageType(page *Page) string {
+	if t, ok := page.frontMatter["type"].(string); ok && t != "" {
+		return t
+	}
+	if i := strings.Index(page.key, "/"); i >= 0 {
+		return page.key[:i]
+	}
+	return ""
+}
+
+// layouts returns the templates looked up for a page in an output format,
+// most specific first. Pages use single.tmpl, sections and the home page
+// list.tmpl, and taxonomies terms.tmpl before list.tmpl, each first in the
+// directory named after the page's type and then in _default. Formats
+// other than HTML add their extension, as in single.json.tmpl.
+func layouts(page *Page, format string) []string {
+	var names []string
+	switch pageKind(page) {
+	case KindPage:
+		names = []string{"single"}
+	case KindTaxonomy:
+		names = []string{"terms", "list"}
+	default:
+		names = []string{"list"}
+	}
+
+	suffix := templateSuffix(format)
+	var candidates []string
+	for _, name := range names {
+		if t := pageType(page); t != "" {
+			candidates = append(candidates, t+"/"+name+suffix)
+		}
+		candidates = append(candidates, "_default/"+name+suffix)
+	}
+	return candidates
+}
+
+// pageTemplate returns the name of the template to render a page with in an
+// output format and why it was chosen. For HTML, that is the template
+// frontmatter, the first layout that exists, or DefaultTemplate. Other
+// formats only use their layouts, and name is "" when there is none.
+func (p *PageProcessor) pageTemplate(page *Page, format string) (name, reason string) {
+	if format != "html" {
+		candidates := layouts(page, format)
+		for _, candidate := range candidates {
+			if p.templates.Lookup(candidate) != nil {
+				return candidate, pageKind(page) + " lookup"
+			}
+		}
+		return "", fmt.Sprintf("no %s", strings.Join(candidates, " or "))
+	}
+
+	if name, ok := page.frontMatter["template"].(string); ok && name != "" {
+		if p.templates.Lookup(name) == nil && p.templates.Lookup(name+".tmpl") != nil {
+			name += ".tmpl"
+		}
+		return name, "set in frontmatter"
+	}
+
+	candidates := layouts(page, format)
+	for _, candidate := range candidates {
+		if p.templates.Lookup(candidate) != nil {
+			return candidate, pageKind(page) + " lookup"
+		}
+	}
+	return DefaultTemplate, fmt.Sprintf("default, no %s", strings.Join(candidates, " or "))
+}
+
+// baseTemplate returns the base template to render a layout in: baseof.tmpl
+// in the layout's directory, else _default/baseof.tmpl, or baseof.json.tmpl
+// and so on for the layouts of other output formats. Only layout files that
+// do nothing but define blocks, such as {{define "main"}}, have a base; for
+// other templates it returns "".
+func (p *PageProcessor) baseTemplate(name string) string {
+	if !strings.HasSuffix(name, ".tmpl") || !sitetemplate.IsLayoutOnly(p.templates.Lookup(name)) {
+		return ""
+	}
+	baseof := "baseof.tmpl"
+	if ext := path.Ext(strings.TrimSuffix(name, ".tmpl")); ext != ".html" {
+		for _, format := range config.OutputFormats {
+			if format.Extension == ext {
+				baseof = "baseof" + ext + ".tmpl"
+			}
+		}
+	}
+	for _, base := range []string{path.Join(path.Dir(name), baseof), "_default/" + baseof} {
+		if base != name && p.templates.Lookup(base) != nil {
+			return base
+		}
+	}
+	return ""
+}
+
+// withBase returns the template set for rendering a layout inside base, in
+// which the layout's blocks are the only overrides. Sets are built once per
+// pair.
+func (p *PageProcessor) withBase(base, name string) (*template.Template, error) {
+	key := base + "\x00" + name
+	if set, ok := p.layoutSets[key]; ok {
+		return set, nil
+	}
+	set, err := p.loader.WithBase(p.templates, base, name)
+	if err != nil {
+		return nil, err
+	}
+	p.layoutSets[key] = set
+	return set, nil
+}
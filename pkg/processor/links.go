@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// resolveSourceLink rewrites a link to a page's source file, such as
+// "../reviews/reviews-01.md#summary", into that page's permalink. Links to
+// source files that aren't indexed pages are left as they are, with a
+// warning. Links to .html files aren't checked, since they usually point at
+// rendered output.
+func (p *PageProcessor) resolveSourceLink(from *Page, dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || !p.isPageFile(u.Path) {
+		return "", false
+	}
+	target := p.site.lookup(from, u.Path)
+	if target == nil {
+		if strings.ToLower(path.Ext(u.Path)) != ".html" {
+			fmt.Fprintf(os.Stderr, "Warning: %s: unresolved source link %s\n", from.source(), dest)
+		}
+		return "", false
+	}
+
+	link := target.Permalink
+	if u.RawQuery != "" {
+		link += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		link += "#" + u.Fragment
+	}
+	return link, true
+}
+
+// linkedPage returns the page whose source file a link points to, or nil.
+func (p *PageProcessor) linkedPage(from *Page, dest string) *Page {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return nil
+	}
+	if !p.isPageFile(u.Path) {
+		return nil
+	}
+	return p.site.lookup(from, u.Path)
+}
+
+// ref resolves a page reference for the ref and relref functions. The
+// reference may carry a "#fragment" and is resolved relative to from first,
+// then to PagesDir.
+func (p *PageProcessor) ref(from *Page, reference string) (string, error) {
+	sourcePath, fragment := reference, ""
+	if i := strings.Index(reference, "#"); i >= 0 {
+		sourcePath, fragment = reference[:i], reference[i:]
+	}
+
+	target := p.site.lookup(from, sourcePath)
+	if from == nil && p.page != nil {
+		// Layouts link to the version in the language being rendered.
+		if translation := target.translation(p.page.Language); translation != nil {
+			target = translation
+		}
+	}
+	if target == nil {
+		return "", fmt.Errorf("ref %q: page not found", reference)
+	}
+	return target.Permalink + fragment, nil
+}
+
+func (p *PageProcessor) absRef(from *Page, reference string) (string, error) {
+	link, err := p.ref(from, reference)
+	if err != nil {
+		return "", err
+	}
+	return p.config.AbsURL(link), nil
+}
+
+// lookup finds the page for a source path that is either relative to the
+// page from or, when it starts with "/" or isn't found there, to PagesDir.
+// The translation in the language of from is preferred.
+func (s *Site) lookup(from *Page, sourcePath string) *Page {
+	if sourcePath == "" {
+		return nil
+	}
+	page := s.GetPage(sourcePath)
+	if from != nil && !strings.HasPrefix(sourcePath, "/") {
+		if relative := s.GetPage(path.Join(path.Dir(from.Path), sourcePath)); relative != nil {
+			page = relative
+		}
+	}
+	if from != nil {
+		if translation := page.translation(from.Language); translation != nil {
+			page = translation
+		}
+	}
+	return page
+}
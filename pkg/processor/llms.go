@@ -0,0 +1,281 @@
+package processor
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// isMarkdownPage reports whether page is rendered from a Markdown file.
+func isMarkdownPage(page *Page) bool {
+	if page.file == "" {
+		return false
+	}
+	switch strings.ToLower(path.Ext(page.Path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+var (
+	inlineDestination    = regexp.MustCompile(`(\]\(\s*<?)([^\s()<>]+)`)
+	referenceDestination = regexp.MustCompile(`^(\s{0,3}\[[^\]]+\]:\s*<?)([^\s<>]+)`)
+)
+
+// markdownSource returns a Markdown page as clean Markdown for machine
+// readers, headed by its title unless the body starts with a heading of
+// its own. Shortcodes are expanded, fenced admonitions become GitHub-style
+// ones, and wiki links and links to other pages lead to their Markdown
+// copies. Every link is absolute, so the text stands on its own in
+// llms-full.txt.
+func (p *PageProcessor) markdownSource(page *Page) (string, error) {
+	source, err := p.expandShortcodes(&shortcodeContext{page: page, file: page.file}, page.content)
+	if err != nil {
+		return "", err
+	}
+	source = quoteAdmonitions(source)
+
+	var lines []string
+	var code codeFenceTracker
+	for _, line := range strings.Split(source, "\n") {
+		if !code.inCode(strings.TrimSpace(line)) {
+			if line = p.markdownWikiLinks(page, line); line == "" && len(lines) > 0 && lines[len(lines)-1] == "" {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	destinations := map[string]string{}
+	ast.WalkFunc(parseMarkdown([]byte(strings.Join(lines, "\n"))), func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Link:
+			destinations[string(node.Destination)] = p.markdownLinkURL(page, string(node.Destination), true)
+		case *ast.Image:
+			destinations[string(node.Destination)] = p.markdownLinkURL(page, string(node.Destination), false)
+		}
+		return ast.GoToNext
+	})
+	rewrite := func(pattern *regexp.Regexp, line string) string {
+		return pattern.ReplaceAllStringFunc(line, func(match string) string {
+			m := pattern.FindStringSubmatch(match)
+			if dest, ok := destinations[m[2]]; ok {
+				return m[1] + dest
+			}
+			return match
+		})
+	}
+	code = codeFenceTracker{}
+	for i, line := range lines {
+		if !code.inCode(strings.TrimSpace(line)) {
+			lines[i] = rewrite(referenceDestination, rewrite(inlineDestination, line))
+		}
+	}
+
+	body := strings.TrimSpace(strings.Join(lines, "\n"))
+	if !strings.HasPrefix(body, "# ") {
+		body = strings.TrimSpace("# " + page.Title + "\n\n" + body)
+	}
+	return body + "\n", nil
+}
+
+// quoteAdmonitions rewrites :::kind blocks as GitHub-style admonitions,
+// which Markdown readers outside go-static understand as quotes.
+func quoteAdmonitions(source string) string {
+	out, blocks := extractFencedAdmonitions([]byte(source))
+	quoted := string(out)
+	for i, block := range blocks {
+		admonition := "> [!" + strings.ToUpper(block.kind) + "]"
+		if block.title != "" {
+			admonition += " " + block.title
+		}
+		body := strings.Trim(quoteAdmonitions(string(block.body)), "\n")
+		var code codeFenceTracker
+		blank := false
+		for _, line := range strings.Split(body, "\n") {
+			if !code.inCode(strings.TrimSpace(line)) {
+				if line == "" && blank {
+					continue
+				}
+				blank = line == ""
+			}
+			admonition += strings.TrimRight("\n> "+line, " ")
+		}
+		quoted = strings.Replace(quoted, fmt.Sprintf("<!-- go-static:admonition:%d -->", i), admonition, 1)
+	}
+	return quoted
+}
+
+// markdownWikiLinks replaces the [[wiki links]] in a line with Markdown
+// links to the Markdown copies of their pages, or with their labels when
+// they don't resolve.
+func (p *PageProcessor) markdownWikiLinks(page *Page, line string) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(line, func(match string) string {
+		m := wikiLinkPattern.FindStringSubmatch(match)
+		label := m[1]
+		if m[2] != "" {
+			label = m[2]
+		}
+		linked := p.site.resolveWikiLink(m[1])
+		if translation := linked.translation(page.Language); translation != nil {
+			linked = translation
+		}
+		if linked == nil || linked.file == "" {
+			return label
+		}
+		fragment := ""
+		if i := strings.Index(m[1], "#"); i >= 0 {
+			fragment = m[1][i:]
+		}
+		return "[" + label + "](" + p.config.AbsURL(markdownPermalink(linked)) + fragment + ")"
+	})
+}
+
+// markdownLinkURL makes a link in a page's Markdown absolute. Relative
+// links are resolved against the page's source directory, where the files
+// under PagesDir are published. Links to other pages, by source path or
+// permalink, lead to their Markdown copies when toPages is set.
+func (p *PageProcessor) markdownLinkURL(from *Page, dest string, toPages bool) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(dest, "#") {
+		return dest
+	}
+	base := &url.URL{Path: p.config.BasePath() + "/"}
+	if dir := path.Dir(from.Path); dir != "." {
+		base.Path += dir + "/"
+	}
+	resolved := base.ResolveReference(u)
+
+	if toPages {
+		target := p.linkedPage(from, dest)
+		if target == nil {
+			for _, page := range p.site.Pages {
+				if page.Permalink == resolved.Path {
+					target = page
+					break
+				}
+			}
+		}
+		if target != nil {
+			resolved.Path = markdownPermalink(target)
+		}
+	}
+	return p.config.AbsURL(resolved.String())
+}
+
+// writeLLMs writes llms.txt, a Markdown index of the site for language
+// models that links to the Markdown copies of its pages, and llms-full.txt,
+// with the Markdown of every page in one file.
+func (p *PageProcessor) writeLLMs() error {
+	links, err := p.llmsPages()
+	if err != nil {
+		return err
+	}
+
+	title := "Site"
+	if home := p.site.GetPage("index.md"); home != nil {
+		title = home.Title
+	}
+
+	var index strings.Builder
+	fmt.Fprintf(&index, "# %s\n", title)
+	if summary := strings.TrimSpace(p.config.LLMs.Summary); summary != "" {
+		fmt.Fprintf(&index, "\n> %s\n", strings.ReplaceAll(summary, "\n", "\n> "))
+	}
+	index.WriteString("\n## Pages\n\n")
+	for _, page := range links {
+		fmt.Fprintf(&index, "- [%s](%s)", page.Title, p.config.AbsURL(markdownPermalink(page)))
+		if description := llmsDescription(page); description != "" {
+			fmt.Fprintf(&index, ": %s", description)
+		}
+		index.WriteString("\n")
+	}
+
+	var full strings.Builder
+	for _, page := range p.site.Pages {
+		if !isMarkdownPage(page) {
+			continue
+		}
+		markdown, err := p.markdownSource(page)
+		if err != nil {
+			return err
+		}
+		if full.Len() > 0 {
+			full.WriteString("\n---\n\n")
+		}
+		fmt.Fprintf(&full, "<!-- %s -->\n\n%s", p.config.AbsURL(page.Permalink), markdown)
+	}
+
+	if err := os.WriteFile(filepath.Join(p.config.PublicDir, "llms.txt"), []byte(index.String()), 0644); err != nil {
+		return fmt.Errorf("error writing llms.txt: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(p.config.PublicDir, "llms-full.txt"), []byte(full.String()), 0644); err != nil {
+		return fmt.Errorf("error writing llms-full.txt: %w", err)
+	}
+	return nil
+}
+
+// llmsPages returns the pages llms.txt links to: those listed in the
+// config, then those with llms frontmatter that isn't false, or else every
+// Markdown page.
+func (p *PageProcessor) llmsPages() ([]*Page, error) {
+	var pages []*Page
+	listed := map[*Page]bool{}
+	for _, sourcePath := range p.config.LLMs.Pages {
+		page := p.site.GetPage(sourcePath)
+		if page == nil {
+			return nil, fmt.Errorf("llms.pages: no page %s in %s", sourcePath, p.config.PagesDir)
+		}
+		if !listed[page] {
+			listed[page] = true
+			pages = append(pages, page)
+		}
+	}
+	for _, page := range p.site.Pages {
+		value, ok := page.frontMatter["llms"]
+		if !ok || value == false || listed[page] {
+			continue
+		}
+		listed[page] = true
+		pages = append(pages, page)
+	}
+
+	if len(pages) == 0 {
+		for _, page := range p.site.Pages {
+			if isMarkdownPage(page) {
+				pages = append(pages, page)
+			}
+		}
+	}
+	return pages, nil
+}
+
+// llmsDescription is the note after a page's link in llms.txt: its llms
+// frontmatter when that is a string, else its description.
+func llmsDescription(page *Page) string {
+	if description, ok := page.frontMatter["llms"].(string); ok {
+		return strings.TrimSpace(description)
+	}
+	if description, ok := page.frontMatter["description"].(string); ok {
+		return strings.TrimSpace(description)
+	}
+	return ""
+}
+
+// markdownPermalink returns the permalink of a page's Markdown copy, or of
+// the page itself when it has none.
+func markdownPermalink(page *Page) string {
+	for _, format := range page.OutputFormats {
+		if format.Name == "md" {
+			return format.Permalink
+		}
+	}
+	return page.Permalink
+}
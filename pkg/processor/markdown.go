@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ahoglund/go-static/pkg/mathml"
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// renderMarkdown converts the Markdown body of page to HTML. Shortcodes are
+// expanded first, and links to other source files are rewritten to the
+// permalinks of the pages they produce, as are [[wiki links]]. Math between
+// $ or $$ delimiters is rendered to MathML, callout blocks to admonitions,
+// and code blocks are highlighted.
+func (p *PageProcessor) renderMarkdown(page *Page, source string) (string, error) {
+	source, err := p.expandShortcodes(&shortcodeContext{page: page, file: page.file}, source)
+	if err != nil {
+		return "", err
+	}
+
+	doc := parseMarkdown([]byte(source))
+	if err := p.rewriteWikiLinks(page, doc); err != nil {
+		return "", err
+	}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if link, ok := node.(*ast.Link); ok && entering {
+			if permalink, ok := p.resolveSourceLink(page, string(link.Destination)); ok {
+				link.Destination = []byte(permalink)
+			}
+		}
+		return ast.GoToNext
+	})
+
+	var renderErr error
+	renderer := html.NewRenderer(html.RendererOptions{
+		Flags: html.CommonFlags,
+		RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+			switch node := node.(type) {
+			case *admonition:
+				renderAdmonition(w, node, entering)
+				return ast.GoToNext, true
+			case *ast.Math:
+				renderErr = firstError(renderErr, renderMath(w, node.Literal, false))
+				return ast.GoToNext, true
+			case *ast.MathBlock:
+				if entering {
+					renderErr = firstError(renderErr, renderMath(w, node.Literal, true))
+				}
+				return ast.GoToNext, true
+			case *ast.CodeBlock:
+				renderErr = firstError(renderErr, renderCode(w, node))
+				return ast.GoToNext, true
+			}
+			return ast.GoToNext, false
+		},
+	})
+
+	out := markdown.Render(doc, renderer)
+	if renderErr != nil {
+		return "", fmt.Errorf("%s: %w", page.file, renderErr)
+	}
+	return string(out), nil
+}
+
+// parseMarkdown parses source into an AST with admonitions in place and
+// only the $ signs that delimit math read as math.
+func parseMarkdown(source []byte) ast.Node {
+	source, fenced := extractFencedAdmonitions(separateMarkedQuotes(protectDollars(source)))
+	doc := markdown.Parse(source, parser.NewWithExtensions(parser.CommonExtensions))
+	insertFencedAdmonitions(doc, fenced, parseMarkdown)
+	convertBlockquoteAdmonitions(doc)
+	restoreDollars(doc)
+	return doc
+}
+
+func renderMath(w io.Writer, tex []byte, display bool) error {
+	out, err := mathml.Convert(string(tex), display)
+	if err != nil {
+		return err
+	}
+	if display {
+		out = "<p>" + out + "</p>\n"
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+// renderCode highlights a code block with the lexer named by the first
+// word of its info string, as for notebook cells.
+func renderCode(w io.Writer, block *ast.CodeBlock) error {
+	language := ""
+	if fields := strings.Fields(string(block.Info)); len(fields) > 0 {
+		language = fields[0]
+	}
+	out, err := highlight(string(block.Literal), language)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+func firstError(err, next error) error {
+	if err != nil {
+		return err
+	}
+	return next
+}
@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ahoglund/go-static/pkg/mathml"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// The parser reads any two $ signs in a paragraph as inline math, as in
+// "$5 and $10". protectDollars replaces the $ signs that mathml.SplitInline
+// doesn't take for delimiters with these placeholders before parsing, and
+// restoreDollars puts them back.
+const (
+	literalDollar = "\uE000"
+	escapedDollar = "\uE001"
+)
+
+var (
+	textDollars   = strings.NewReplacer(literalDollar, "$", escapedDollar, "$")
+	sourceDollars = strings.NewReplacer(literalDollar, "$", escapedDollar, `\$`)
+)
+
+// protectDollars replaces the $ signs of a Markdown source that don't
+// delimit inline math, or are escaped as \$, with placeholders. Fenced code
+// blocks and code spans are left as they are.
+func protectDollars(source []byte) []byte {
+	var out, paragraph bytes.Buffer
+	flush := func() {
+		out.WriteString(protectInlineDollars(paragraph.String()))
+		paragraph.Reset()
+	}
+
+	var code codeFenceTracker
+	for _, line := range bytes.SplitAfter(source, []byte("\n")) {
+		trimmed := strings.TrimSpace(string(line))
+		if code.inCode(trimmed) || trimmed == "" || admonitionFence.MatchString(trimmed) {
+			flush()
+			out.Write(line)
+			continue
+		}
+		paragraph.Write(line)
+	}
+	flush()
+
+	return out.Bytes()
+}
+
+// protectInlineDollars protects the $ signs of one paragraph outside of its
+// code spans.
+func protectInlineDollars(text string) string {
+	var b strings.Builder
+	for text != "" {
+		start, end := codeSpan(text)
+		if start < 0 {
+			start, end = len(text), len(text)
+		}
+		for _, segment := range mathml.SplitInline(text[:start]) {
+			if segment.Math {
+				b.WriteString("$" + segment.Text + "$")
+				continue
+			}
+			b.WriteString(protectLiteralDollars(segment.Text))
+		}
+		b.WriteString(text[start:end])
+		text = text[end:]
+	}
+	return b.String()
+}
+
+// protectLiteralDollars replaces \$ and single $ signs with placeholders,
+// keeping $$.
+func protectLiteralDollars(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.HasPrefix(text[i:], `\$`):
+			b.WriteString(escapedDollar)
+			i++
+		case text[i] == '\\' && i+1 < len(text):
+			b.WriteString(text[i : i+2])
+			i++
+		case strings.HasPrefix(text[i:], "$$"):
+			for i < len(text) && text[i] == '$' {
+				b.WriteByte('$')
+				i++
+			}
+			i--
+		case text[i] == '$':
+			b.WriteString(literalDollar)
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+	return b.String()
+}
+
+// codeSpan returns the bounds of the first code span in text, including its
+// backticks, or -1, -1.
+func codeSpan(text string) (int, int) {
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		n := 1
+		for i+n < len(text) && text[i+n] == '`' {
+			n++
+		}
+		fence := text[i : i+n]
+		for j := i + n; j < len(text); {
+			k := strings.Index(text[j:], fence)
+			if k < 0 {
+				break
+			}
+			j += k
+			m := len(fence)
+			for j+m < len(text) && text[j+m] == '`' {
+				m++
+			}
+			if m == len(fence) {
+				return i, j + m
+			}
+			j += m
+		}
+		i += n
+	}
+	return -1, -1
+}
+
+// restoreDollars puts back the $ signs protected by protectDollars: as
+// plain dollar signs in text, and as written everywhere else.
+func restoreDollars(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.Text:
+			node.Literal = []byte(textDollars.Replace(string(node.Literal)))
+			return ast.GoToNext
+		case *ast.Link:
+			node.Destination = []byte(sourceDollars.Replace(string(node.Destination)))
+			node.Title = []byte(sourceDollars.Replace(string(node.Title)))
+		case *ast.Image:
+			node.Destination = []byte(sourceDollars.Replace(string(node.Destination)))
+			node.Title = []byte(sourceDollars.Replace(string(node.Title)))
+		}
+		if leaf := node.AsLeaf(); leaf != nil {
+			leaf.Literal = []byte(sourceDollars.Replace(string(leaf.Literal)))
+		}
+		return ast.GoToNext
+	})
+}
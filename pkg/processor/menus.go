@@ -0,0 +1,232 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// MenuEntry is an entry of a navigation menu, linking to a page or to an
+// external URL.
+type MenuEntry struct {
+	Name string
+	URL  string
+	// Page is the linked page, or nil for entries with a URL of their own.
+	Page       *Page
+	Weight     int
+	Identifier string
+	Parent     string
+	Children   Menu
+}
+
+// Menu is a list of entries sorted by weight, then name.
+type Menu []*MenuEntry
+
+// HasChildren reports whether other entries are nested under e.
+func (e *MenuEntry) HasChildren() bool {
+	return len(e.Children) > 0
+}
+
+// IsCurrent reports whether e links to page.
+func (e *MenuEntry) IsCurrent(page *Page) bool {
+	if page == nil {
+		return false
+	}
+	if e.Page != nil {
+		return e.Page == page
+	}
+	return e.URL == page.Permalink
+}
+
+// IsActive reports whether e or one of its descendants links to page, so
+// a parent entry is highlighted while one of its children is shown.
+func (e *MenuEntry) IsActive(page *Page) bool {
+	if e.IsCurrent(page) {
+		return true
+	}
+	for _, child := range e.Children {
+		if child.IsActive(page) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildMenus collects the entries of every menu from config.yaml and from
+// the menu frontmatter of pages, and nests entries under their parents.
+//
+// In frontmatter, menu is a menu name, a list of names, or a map from names
+// to entry settings:
+//
+//	menu: main
+//	weight: 10
+//
+//	menu:
+//	  main:
+//	    name: Guides
+//	    parent: Docs
+//	    weight: 10
+func (p *PageProcessor) buildMenus(site *Site) error {
+	entries := map[string][]*MenuEntry{}
+
+	for name, configured := range p.config.Menus {
+		for _, c := range configured {
+			entry := &MenuEntry{
+				Name:       c.Name,
+				URL:        c.URL,
+				Weight:     c.Weight,
+				Identifier: c.Identifier,
+				Parent:     c.Parent,
+			}
+			if c.Page != "" {
+				entry.Page = site.GetPage(c.Page)
+				if entry.Page == nil {
+					return fmt.Errorf("menus.%s: page %q not found", name, c.Page)
+				}
+				if translation := entry.Page.translation(site.Language); translation != nil {
+					entry.Page = translation
+				}
+				if entry.Name == "" {
+					entry.Name = entry.Page.Title
+				}
+			}
+			entries[name] = append(entries[name], entry)
+		}
+	}
+
+	for _, page := range site.Pages {
+		menus, err := pageMenus(page)
+		if err != nil {
+			return fmt.Errorf("%s: %w", page.file, err)
+		}
+		for name, entry := range menus {
+			entries[name] = append(entries[name], entry)
+		}
+	}
+
+	site.Menus = map[string]Menu{}
+	for name, menuEntries := range entries {
+		site.Menus[name] = nestMenu(name, menuEntries)
+	}
+	return nil
+}
+
+// pageMenus reads a page's menu frontmatter into one entry per menu.
+func pageMenus(page *Page) (map[string]*MenuEntry, error) {
+	y := page.frontMatter
+	newEntry := func() *MenuEntry {
+		entry := &MenuEntry{Name: page.Title, Page: page}
+		entry.Weight, _ = y["weight"].(int)
+		entry.Parent, _ = y["parent"].(string)
+		return entry
+	}
+
+	menus := map[string]*MenuEntry{}
+	switch menu := y["menu"].(type) {
+	case nil:
+	case string:
+		menus[menu] = newEntry()
+	case []interface{}:
+		for _, name := range menu {
+			menus[fmt.Sprint(name)] = newEntry()
+		}
+	case map[string]interface{}, map[interface{}]interface{}:
+		for name, settings := range stringMap(menu) {
+			entry := newEntry()
+			if settings := stringMap(settings); settings != nil {
+				if v, ok := settings["name"].(string); ok {
+					entry.Name = v
+				}
+				if v, ok := settings["weight"].(int); ok {
+					entry.Weight = v
+				}
+				if v, ok := settings["parent"].(string); ok {
+					entry.Parent = v
+				}
+				if v, ok := settings["identifier"].(string); ok {
+					entry.Identifier = v
+				}
+			}
+			menus[name] = entry
+		}
+	default:
+		return nil, fmt.Errorf("menu must be a menu name, a list of names or a map, not %T", menu)
+	}
+	return menus, nil
+}
+
+// stringMap returns a YAML mapping with string keys, or nil if v isn't one.
+func stringMap(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = value
+		}
+		return m
+	}
+	return nil
+}
+
+// nestMenu moves entries under the entry their Parent names and returns the
+// top-level entries. Entries with an unknown parent stay at the top level.
+func nestMenu(name string, entries []*MenuEntry) Menu {
+	byIdentifier := map[string]*MenuEntry{}
+	for _, entry := range entries {
+		if entry.Identifier == "" {
+			entry.Identifier = entry.Name
+		}
+		if entry.URL == "" && entry.Page != nil {
+			entry.URL = entry.Page.Permalink
+		}
+		byIdentifier[entry.Identifier] = entry
+	}
+
+	var menu Menu
+	for _, entry := range entries {
+		if entry.Parent == "" {
+			menu = append(menu, entry)
+			continue
+		}
+		parent, ok := byIdentifier[entry.Parent]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: menu %s: parent %q of %q not found\n", name, entry.Parent, entry.Name)
+			menu = append(menu, entry)
+			continue
+		}
+		if isAncestor(entry, parent, byIdentifier) {
+			fmt.Fprintf(os.Stderr, "Warning: menu %s: %q is its own ancestor\n", name, entry.Name)
+			menu = append(menu, entry)
+			continue
+		}
+		parent.Children = append(parent.Children, entry)
+	}
+
+	sortMenu(menu)
+	return menu
+}
+
+// isAncestor reports whether ancestor is entry itself or one of its parents.
+func isAncestor(ancestor, entry *MenuEntry, byIdentifier map[string]*MenuEntry) bool {
+	for seen := 0; entry != nil && seen <= len(byIdentifier); seen++ {
+		if entry == ancestor {
+			return true
+		}
+		entry = byIdentifier[entry.Parent]
+	}
+	return false
+}
+
+func sortMenu(menu Menu) {
+	sort.SliceStable(menu, func(i, j int) bool {
+		if menu[i].Weight != menu[j].Weight {
+			return menu[i].Weight < menu[j].Weight
+		}
+		return menu[i].Name < menu[j].Name
+	})
+	for _, entry := range menu {
+		sortMenu(entry.Children)
+	}
+}
@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ahoglund/go-static/pkg/config"
+)
+
+// dateLayouts are the formats accepted for date frontmatter given as a
+// string rather than a YAML timestamp.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// indexNavigation computes the breadcrumbs, previous and next pages and
+// related pages of every page. It needs the complete page index.
+func (p *PageProcessor) indexNavigation(site *Site) {
+	sections := map[string][]*Page{}
+	for _, page := range site.Pages {
+		dir := path.Dir(page.key)
+
+		for ancestor := dir; ; ancestor = path.Dir(ancestor) {
+			if isIndexPage(page) && ancestor == dir {
+				// An index page is its directory's section, so its
+				// ancestors start at the parent directory.
+				if dir == "." {
+					break
+				}
+				continue
+			}
+			if bundle := site.bundle(ancestor); bundle != nil {
+				page.Ancestors = append([]*Page{bundle}, page.Ancestors...)
+			}
+			if ancestor == "." {
+				break
+			}
+		}
+
+		if !isIndexPage(page) {
+			sections[dir] = append(sections[dir], page)
+		}
+	}
+
+	for _, pages := range sections {
+		sort.SliceStable(pages, func(i, j int) bool {
+			return pageLess(pages[i], pages[j])
+		})
+		for i, page := range pages {
+			if i > 0 {
+				page.PrevInSection = pages[i-1]
+			}
+			if i < len(pages)-1 {
+				page.NextInSection = pages[i+1]
+			}
+		}
+	}
+
+	keys := p.config.Related.Keys
+	if len(keys) == 0 {
+		keys = config.DefaultRelatedKeys
+	}
+	for _, page := range site.Pages {
+		page.Related = relatedPages(page, site.Pages, keys, p.config.Related.Limit)
+	}
+}
+
+func isIndexPage(page *Page) bool {
+	return strings.TrimSuffix(path.Base(page.key), path.Ext(page.key)) == "index"
+}
+
+// pageLess orders the pages of a section by weight, with weighted pages
+// first, then by date, oldest first, then by title.
+func pageLess(a, b *Page) bool {
+	wa, _ := a.frontMatter["weight"].(int)
+	wb, _ := b.frontMatter["weight"].(int)
+	if wa != wb {
+		if wa == 0 || wb == 0 {
+			return wb == 0
+		}
+		return wa < wb
+	}
+	if da, db := pageDate(a), pageDate(b); !da.Equal(db) {
+		return da.Before(db)
+	}
+	return a.Title < b.Title
+}
+
+// pageDate returns the date frontmatter of a page, or the zero time.
+func pageDate(page *Page) time.Time {
+	return dateValue(page.frontMatter["date"])
+}
+
+// dateValue returns a frontmatter date given as a YAML timestamp or a
+// string, or the zero time.
+func dateValue(v interface{}) time.Time {
+	switch date := v.(type) {
+	case time.Time:
+		return date
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, date); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// relatedPages ranks the other pages by the values they share with page
+// under each of keys, weighted by the key's weight, and returns the best
+// ones, up to limit. Ties go to the newer page.
+func relatedPages(page *Page, pages []*Page, keys map[string]int, limit int) []*Page {
+	type candidate struct {
+		page  *Page
+		score int
+	}
+
+	var candidates []candidate
+	for _, other := range pages {
+		if other == page {
+			continue
+		}
+		score := 0
+		for key, weight := range keys {
+			values := frontMatterValues(page.frontMatter[key])
+			for value := range frontMatterValues(other.frontMatter[key]) {
+				if values[value] {
+					score += weight
+				}
+			}
+		}
+		if score > 0 {
+			candidates = append(candidates, candidate{other, score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		if di, dj := pageDate(candidates[i].page), pageDate(candidates[j].page); !di.Equal(dj) {
+			return di.After(dj)
+		}
+		return candidates[i].page.Title < candidates[j].page.Title
+	})
+
+	var related []*Page
+	for _, c := range candidates {
+		if limit > 0 && len(related) == limit {
+			break
+		}
+		related = append(related, c.page)
+	}
+	return related
+}
+
+// frontMatterValues returns the set of values of a frontmatter field that
+// is a single value or a list, compared case-insensitively.
+func frontMatterValues(v interface{}) map[string]bool {
+	values := map[string]bool{}
+	switch v := v.(type) {
+	case nil:
+	case []interface{}:
+		for _, value := range v {
+			values[strings.ToLower(fmt.Sprint(value))] = true
+		}
+	default:
+		values[strings.ToLower(fmt.Sprint(v))] = true
+	}
+	return values
+}
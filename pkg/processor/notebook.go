@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"gopkg.in/yaml.v3"
+)
+
+// highlightStyle is the Chroma style used for notebook code cells.
+const highlightStyle = "github"
+
+var (
+	notebookHeading = regexp.MustCompile(`(?m)^#{1,6}[ \t]+(.+?)[ \t#]*$`)
+	ansiEscape      = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+	xmlProlog       = regexp.MustCompile(`(?s)^\s*(?:<\?xml.*?\?>\s*)?(?:<!DOCTYPE.*?>\s*)?`)
+)
+
+// notebook is the part of the Jupyter .ipynb format (nbformat 4) that pages
+// are rendered from.
+type notebook struct {
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		Title      string `json:"title"`
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+type notebookCell struct {
+	CellType    string                          `json:"cell_type"`
+	Source      multiline                       `json:"source"`
+	Attachments map[string]map[string]multiline `json:"attachments"`
+	Outputs     []notebookOutput                `json:"outputs"`
+}
+
+type notebookOutput struct {
+	OutputType string               `json:"output_type"`
+	Name       string               `json:"name"`
+	Text       multiline            `json:"text"`
+	Data       map[string]multiline `json:"data"`
+	EName      string               `json:"ename"`
+	EValue     string               `json:"evalue"`
+	Traceback  []string             `json:"traceback"`
+}
+
+// multiline is a notebook string, stored either as one string or as a list
+// of lines.
+type multiline string
+
+func (m *multiline) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*m = multiline(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*m = multiline(s)
+	return nil
+}
+
+// notebookConverter renders Jupyter notebooks: Markdown cells through the
+// Markdown pipeline, code cells highlighted, followed by their outputs.
+type notebookConverter struct {
+	p *PageProcessor
+}
+
+// ReadFrontMatter takes the frontmatter from a leading raw cell holding a
+// YAML frontmatter block, if there is one. The title defaults to the
+// notebook's metadata title, then to its first Markdown heading.
+func (c notebookConverter) ReadFrontMatter(source string) (map[interface{}]interface{}, string, error) {
+	nb, err := parseNotebook(source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	y := map[interface{}]interface{}{}
+	if len(nb.Cells) > 0 && nb.Cells[0].CellType == "raw" {
+		if data := strings.SplitN(string(nb.Cells[0].Source), FrontMatterDelimiter, 3); len(data) == 3 && strings.TrimSpace(data[0]) == "" {
+			if err := yaml.Unmarshal([]byte(data[1]), &y); err != nil {
+				return nil, "", fmt.Errorf("error parsing YAML in raw cell: %w", err)
+			}
+			if y == nil {
+				y = map[interface{}]interface{}{}
+			}
+		}
+	}
+
+	if _, ok := y["title"]; !ok {
+		if nb.Metadata.Title != "" {
+			y["title"] = nb.Metadata.Title
+		} else {
+			for _, cell := range nb.Cells {
+				if cell.CellType != "markdown" {
+					continue
+				}
+				if m := notebookHeading.FindStringSubmatch(string(cell.Source)); m != nil {
+					y["title"] = m[1]
+					break
+				}
+			}
+		}
+	}
+
+	return y, source, nil
+}
+
+func (c notebookConverter) Convert(page *Page, source string) (string, error) {
+	nb, err := parseNotebook(source)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", page.file, err)
+	}
+
+	language := nb.Metadata.LanguageInfo.Name
+	if language == "" {
+		language = nb.Metadata.KernelSpec.Language
+	}
+	if language == "" {
+		language = "python"
+	}
+
+	var b strings.Builder
+	for _, cell := range nb.Cells {
+		switch cell.CellType {
+		case "markdown":
+			out, err := c.p.renderMarkdown(page, inlineAttachments(string(cell.Source), cell.Attachments))
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(out)
+		case "code":
+			if strings.TrimSpace(string(cell.Source)) == "" && len(cell.Outputs) == 0 {
+				continue
+			}
+			code, err := highlight(string(cell.Source), language)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", page.file, err)
+			}
+			b.WriteString("<div class=\"notebook-cell\">\n<div class=\"notebook-input\">")
+			b.WriteString(code)
+			b.WriteString("</div>\n")
+			for _, output := range cell.Outputs {
+				b.WriteString(renderNotebookOutput(output))
+			}
+			b.WriteString("</div>\n")
+		}
+	}
+
+	return b.String(), nil
+}
+
+func parseNotebook(source string) (*notebook, error) {
+	var nb notebook
+	if err := json.Unmarshal([]byte(source), &nb); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+	return &nb, nil
+}
+
+// inlineAttachments replaces attachment:name references in a Markdown cell
+// with data URIs of the attached files.
+func inlineAttachments(source string, attachments map[string]map[string]multiline) string {
+	names := make([]string, 0, len(attachments))
+	for name := range attachments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for mime, data := range attachments[name] {
+			source = strings.ReplaceAll(source, "attachment:"+name, dataURI(mime, string(data)))
+			break
+		}
+	}
+	return source
+}
+
+// renderNotebookOutput renders a code cell output. Of the representations
+// of a result, HTML is preferred, then SVG, PNG, JPEG and plain text.
+func renderNotebookOutput(output notebookOutput) string {
+	switch output.OutputType {
+	case "stream":
+		return fmt.Sprintf("<pre class=\"notebook-output notebook-%s\">%s</pre>\n",
+			html.EscapeString(output.Name), html.EscapeString(ansiEscape.ReplaceAllString(string(output.Text), "")))
+	case "error":
+		text := strings.Join(output.Traceback, "\n")
+		if text == "" {
+			text = output.EName + ": " + output.EValue
+		}
+		return "<pre class=\"notebook-output notebook-error\">" + html.EscapeString(ansiEscape.ReplaceAllString(text, "")) + "</pre>\n"
+	case "execute_result", "display_data":
+		data := output.Data
+		switch {
+		case data["text/html"] != "":
+			return "<div class=\"notebook-output\">" + string(data["text/html"]) + "</div>\n"
+		case data["image/svg+xml"] != "":
+			return "<div class=\"notebook-output\">" + xmlProlog.ReplaceAllString(string(data["image/svg+xml"]), "") + "</div>\n"
+		case data["image/png"] != "":
+			return "<div class=\"notebook-output\"><img src=\"" + dataURI("image/png", string(data["image/png"])) + "\" alt=\"\"></div>\n"
+		case data["image/jpeg"] != "":
+			return "<div class=\"notebook-output\"><img src=\"" + dataURI("image/jpeg", string(data["image/jpeg"])) + "\" alt=\"\"></div>\n"
+		case data["text/plain"] != "":
+			return "<pre class=\"notebook-output\">" + html.EscapeString(string(data["text/plain"])) + "</pre>\n"
+		}
+	}
+	return ""
+}
+
+// dataURI embeds base64 data, as stored in notebooks, in a URI.
+func dataURI(mime, data string) string {
+	return "data:" + mime + ";base64," + strings.Join(strings.Fields(data), "")
+}
+
+// highlight renders code as HTML with inline styles, using the lexer for
+// language or plain text if there is none.
+func highlight(code, language string) (string, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return "", fmt.Errorf("error highlighting %s code: %w", language, err)
+	}
+
+	var b strings.Builder
+	if err := chromahtml.New(chromahtml.TabWidth(4)).Format(&b, styles.Get(highlightStyle), iterator); err != nil {
+		return "", fmt.Errorf("error highlighting %s code: %w", language, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ahoglund/go-static/pkg/org"
+)
+
+// orgTimestamp matches an org timestamp such as <2024-01-15 Mon> or
+// [2024-01-15 Mon 10:30].
+var orgTimestamp = regexp.MustCompile(`^[<\[](\d{4}-\d{2}-\d{2})(?:\s+[^\s\d>\]]+)?(?:\s+(\d{1,2}:\d{2}))?[>\]]$`)
+
+// orgConverter renders .org pages. Their #+KEYWORD: lines take the place of
+// YAML frontmatter.
+type orgConverter struct {
+	p *PageProcessor
+}
+
+// ReadFrontMatter maps the document's keywords to frontmatter: #+TITLE to
+// title, #+DATE to date, #+TAGS and #+FILETAGS to a tags list, and any other
+// keyword, such as #+TEMPLATE or #+SLUG, to its lowercase name.
+func (c orgConverter) ReadFrontMatter(source string) (map[interface{}]interface{}, string, error) {
+	y := map[interface{}]interface{}{}
+	keywords := org.Keywords(source)
+
+	var tags []interface{}
+	for _, key := range []string{"filetags", "tags"} {
+		for _, tag := range strings.FieldsFunc(keywords[key], func(r rune) bool {
+			return r == ':' || r == ',' || r == ' ' || r == '\t'
+		}) {
+			tags = append(tags, tag)
+		}
+	}
+
+	for key, value := range keywords {
+		switch key {
+		case "tags", "filetags":
+		case "date":
+			if m := orgTimestamp.FindStringSubmatch(value); m != nil {
+				value = strings.TrimSpace(m[1] + " " + m[2])
+			}
+			y[key] = value
+		default:
+			y[key] = value
+		}
+	}
+	if tags != nil {
+		y["tags"] = tags
+	}
+
+	return y, source, nil
+}
+
+func (c orgConverter) Convert(page *Page, source string) (string, error) {
+	return org.ToHTML(source, org.Options{
+		Link: func(dest string) string {
+			if link, ok := c.p.resolveSourceLink(page, dest); ok {
+				return link
+			}
+			return dest
+		},
+	}), nil
+}
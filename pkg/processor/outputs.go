@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ahoglund/go-static/pkg/config"
+	sitetemplate "github.com/ahoglund/go-static/pkg/template"
+)
+
+// OutputFormat is one of the files a page is rendered to.
+type OutputFormat struct {
+	Name      string
+	MediaType string
+	// Rel is "canonical" for HTML and "alternate" for the other formats,
+	// for <link rel> tags.
+	Rel        string
+	Permalink  string
+	OutputPath string
+}
+
+// pageOutputs returns the names of the formats a page is rendered to: its
+// outputs frontmatter, else the formats configured for its kind, else html,
+// and md for Markdown pages when llms.txt is enabled.
+func pageOutputs(cfg *config.Config, page *Page) ([]string, error) {
+	names := cfg.Outputs[pageKind(page)]
+	if value, ok := page.frontMatter["outputs"]; ok {
+		names = nil
+		switch value := value.(type) {
+		case string:
+			names = []string{value}
+		case []interface{}:
+			for _, name := range value {
+				names = append(names, fmt.Sprint(name))
+			}
+		default:
+			return nil, fmt.Errorf("outputs must be a list of formats")
+		}
+		for _, name := range names {
+			if _, ok := config.OutputFormats[name]; !ok {
+				return nil, fmt.Errorf("unknown output format %q", name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"html"}
+	}
+	if cfg.LLMs.Enabled && isMarkdownPage(page) {
+		// Every Markdown page gets a mirror for llms.txt to link to.
+		mirrored := false
+		for _, name := range names {
+			mirrored = mirrored || name == "md"
+		}
+		if !mirrored {
+			names = append(names, "md")
+		}
+	}
+	return names, nil
+}
+
+// outputFormats places a page's formats next to its HTML file: about.html
+// gets about.json and about/index.html gets about/index.json. The first
+// format becomes the page's own OutputPath and Permalink.
+func outputFormats(page *Page, names []string) []*OutputFormat {
+	formats := make([]*OutputFormat, 0, len(names))
+	for _, name := range names {
+		format := config.OutputFormats[name]
+		output := &OutputFormat{
+			Name:       name,
+			MediaType:  format.MediaType,
+			Rel:        "alternate",
+			Permalink:  page.Permalink,
+			OutputPath: page.OutputPath,
+		}
+		if name == "html" {
+			output.Rel = "canonical"
+		} else {
+			output.OutputPath = strings.TrimSuffix(page.OutputPath, path.Ext(page.OutputPath)) + format.Extension
+			if strings.HasSuffix(page.Permalink, "/") {
+				output.Permalink = page.Permalink + "index" + format.Extension
+			} else {
+				output.Permalink = strings.TrimSuffix(page.Permalink, path.Ext(page.Permalink)) + format.Extension
+			}
+		}
+		formats = append(formats, output)
+	}
+	page.OutputPath, page.Permalink = formats[0].OutputPath, formats[0].Permalink
+	return formats
+}
+
+// alternatives returns the page's formats other than format.
+func alternatives(page *Page, format *OutputFormat) []*OutputFormat {
+	var others []*OutputFormat
+	for _, other := range page.OutputFormats {
+		if other != format {
+			others = append(others, other)
+		}
+	}
+	return others
+}
+
+// templateSuffix is the end of the layout names of a format: .tmpl for
+// HTML and, e.g., .json.tmpl for JSON.
+func templateSuffix(format string) string {
+	if format == "html" {
+		return ".tmpl"
+	}
+	return config.OutputFormats[format].Extension + ".tmpl"
+}
+
+// defaultOutput renders a format that has no layout for the page, if the
+// format has a built-in rendering: JSON with the page's data and rendered
+// content, and Markdown as the source of a Markdown page. ok is false for
+// other formats and sources.
+func (p *PageProcessor) defaultOutput(page *Page, format, content string) (output string, ok bool, err error) {
+	switch format {
+	case "json":
+		data := map[string]interface{}{
+			"title":       page.Title,
+			"path":        page.Path,
+			"kind":        pageKind(page),
+			"permalink":   p.config.AbsURL(page.Permalink),
+			"content":     content,
+			"frontmatter": sitetemplate.JSONValue(page.frontMatter),
+		}
+		if page.Language.Code != "" {
+			data["language"] = page.Language.Code
+		}
+		if date := pageDate(page); !date.IsZero() {
+			data["date"] = date
+		}
+		if !page.Lastmod.IsZero() {
+			data["lastmod"] = page.Lastmod
+		}
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			return "", false, fmt.Errorf("error encoding JSON for %s: %w", page.source(), err)
+		}
+		return buf.String(), true, nil
+	case "md":
+		if isMarkdownPage(page) {
+			markdown, err := p.markdownSource(page)
+			return markdown, err == nil, err
+		}
+	}
+	return "", false, nil
+}
@@ -11,82 +11,206 @@ import (
 
 	"github.com/ahoglund/go-static/pkg/assets"
 	"github.com/ahoglund/go-static/pkg/config"
-	"github.com/gomarkdown/markdown"
-	"gopkg.in/yaml.v3"
+	sitetemplate "github.com/ahoglund/go-static/pkg/template"
 )
 
 type PageProcessor struct {
-	config    *config.Config
-	templates *template.Template
+	config     *config.Config
+	templates  *template.Template
+	site       *Site
+	// converters render page files, keyed by extension.
+	converters map[string]Converter
+	// page is the page being rendered, for template functions that depend
+	// on its language.
+	page *Page
+	// translations are the i18n strings of each language code.
+	translations        map[string]map[string]interface{}
+	missingTranslations map[string]bool
+	// partialCache holds the output of partialCached by template name and
+	// variant keys, for the whole build.
+	partialCache map[string]string
+	partialStats map[string]*PartialStats
+	// loader finds the files of templates, to render layouts in a base
+	// template and to show where template errors are.
+	loader *sitetemplate.TemplateLoader
+	// layoutSets are the template sets of layouts rendered in a base
+	// template, keyed by base and layout.
+	layoutSets map[string]*template.Template
+	// geminiFiles are the files linked from gemtext pages that are copied
+	// to the capsule, by their path in GeminiDir.
+	geminiFiles map[string]string
+
+	// Verbose reports the template chosen for each page.
+	Verbose bool
 }
 
 func NewPageProcessor(cfg *config.Config, templates *template.Template) *PageProcessor {
-	return &PageProcessor{
-		config:    cfg,
-		templates: templates,
+	p := &PageProcessor{
+		config:              cfg,
+		templates:           templates,
+		missingTranslations: map[string]bool{},
+		layoutSets:          map[string]*template.Template{},
+		loader:              sitetemplate.NewTemplateLoader(cfg),
+		partialCache:        map[string]string{},
+		partialStats:        map[string]*PartialStats{},
+	}
+	p.converters = p.builtinConverters()
+	for ext, c := range converters {
+		p.converters[ext] = c
 	}
+	for ext, c := range cfg.Converters {
+		p.converters[normalizeExt(ext)] = commandConverter{p: p, cfg: c}
+	}
+	templates.Funcs(p.templateFuncs())
+	return p
 }
 
+// ProcessPage renders a page file under PagesDir. Any other file, such as an
+// image in a page bundle, is copied to the same relative path under
+// PublicDir.
 func (p *PageProcessor) ProcessPage(file string) error {
-	content, err := os.ReadFile(file)
-	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", file, err)
+	if p.site == nil {
+		if err := p.IndexPages(); err != nil {
+			return err
+		}
+	}
+
+	converter := p.converter(file)
+	if converter == nil {
+		if strings.HasPrefix(filepath.Base(file), ".") {
+			return nil
+		}
+		return p.copyResource(file)
+	}
+
+	page := p.site.GetPage(p.sourcePath(file))
+	if page == nil {
+		var err error
+		page, err = p.loadPage(p.site, file)
+		if err != nil {
+			return err
+		}
 	}
 
-	data := strings.Split(string(content), FrontMatterDelimiter)
-	if len(data) < 3 {
-		return fmt.Errorf("invalid frontmatter format in file %s", file)
+	p.page = page
+	defer func() { p.page = nil }()
+
+	content, err := converter.Convert(page, page.content)
+	if err != nil {
+		return err
 	}
 
-	rawFrontMatter := data[1]
-	rawContent := data[2]
+	return p.renderPage(page, content)
+}
 
-	var y map[interface{}]interface{}
-	err = yaml.Unmarshal([]byte(rawFrontMatter), &y)
+// renderPage executes the page's layout around its rendered content and
+// writes the result to the page's output path.
+func (p *PageProcessor) renderPage(page *Page, content string) error {
+	content, err := p.applyFilters(config.FilterContent, page, content)
 	if err != nil {
-		return fmt.Errorf("error parsing YAML in file %s: %w", file, err)
+		return err
+	}
+
+	// Layouts are executed with a copy of the frontmatter, so the values
+	// added for them don't become part of the page's own.
+	y := make(map[interface{}]interface{}, len(page.frontMatter)+20)
+	for key, value := range page.frontMatter {
+		y[key] = value
 	}
+	y["content"] = content
+	y["permalink"] = page.Permalink
+	y["Backlinks"] = page.Backlinks
+	y["Resources"] = page.Resources
+	y["Ancestors"] = page.Ancestors
+	y["PrevInSection"] = page.PrevInSection
+	y["NextInSection"] = page.NextInSection
+	y["Related"] = page.Related
+	y["Translations"] = page.Translations
+	y["Lastmod"] = page.Lastmod
+	y["GitInfo"] = page.GitInfo
+	y["EditURL"] = page.EditURL
+	y["OutputFormats"] = page.OutputFormats
+	y["Page"] = page
+	y["Site"] = p.site.languageSite(page.Language)
 
-	if _, ok := y["template"]; !ok {
-		y["template"] = DefaultTemplate
+	for _, format := range page.OutputFormats {
+		if err := p.renderFormat(page, format, content, y); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// renderFormat writes a page in one of its output formats, with the
+// format's layout executed with y or else its built-in rendering. Formats
+// with neither are skipped with a warning.
+func (p *PageProcessor) renderFormat(page *Page, format *OutputFormat, content string, y map[interface{}]interface{}) error {
+	y["OutputFormat"] = format
+	y["AlternativeOutputFormats"] = alternatives(page, format)
 
-	if _, ok := y["title"]; !ok {
-		return fmt.Errorf("file %s doesn't contain a title", file)
+	label := "Template"
+	if format.Name != "html" {
+		label = fmt.Sprintf("Template (%s)", format.Name)
 	}
 
-	var parsedPageBuf bytes.Buffer
-	switch filepath.Ext(file) {
-	case ".html":
-		fmt.Fprint(&parsedPageBuf, rawContent)
-	case ".md":
-		fmt.Fprint(&parsedPageBuf, string(markdown.ToHTML([]byte(rawContent), nil, nil)))
-	case ".tmpl":
-		parsedTemplate, err := template.New(file).Parse(rawContent)
+	name, reason := p.pageTemplate(page, format.Name)
+	if name == "" {
+		output, ok, err := p.defaultOutput(page, format.Name, content)
 		if err != nil {
-			return fmt.Errorf("error parsing template %s: %w", file, err)
+			return err
 		}
-		err = parsedTemplate.Execute(&parsedPageBuf, y)
-		if err != nil {
-			return fmt.Errorf("error executing template %s: %w", file, err)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s output of %s: %s\n", format.Name, page.source(), reason)
+			return nil
 		}
-	default:
-		return fmt.Errorf("unsupported file type: %s", filepath.Ext(file))
+		if p.Verbose {
+			fmt.Printf("  %s: built-in (%s)\n", label, reason)
+		}
+		if err := p.writeTemplate(format.OutputPath, output); err != nil {
+			return fmt.Errorf("error writing template: %w", err)
+		}
+		return nil
 	}
 
-	y["content"] = parsedPageBuf.String()
+	base := p.baseTemplate(name)
+	if p.Verbose {
+		if base != "" {
+			fmt.Printf("  %s: %s in %s (%s)\n", label, name, base, reason)
+		} else {
+			fmt.Printf("  %s: %s (%s)\n", label, name, reason)
+		}
+	}
+	y["template"] = name
 
 	var parsedTemplateBuf bytes.Buffer
-	err = p.templates.ExecuteTemplate(&parsedTemplateBuf, y["template"].(string), y)
+	var err error
+	if base != "" {
+		var set *template.Template
+		set, err = p.withBase(base, name)
+		if err != nil {
+			return fmt.Errorf("error loading template: %w", err)
+		}
+		err = set.ExecuteTemplate(&parsedTemplateBuf, base, y)
+	} else {
+		err = p.templates.ExecuteTemplate(&parsedTemplateBuf, name, y)
+	}
 	if err != nil {
-		return fmt.Errorf("error executing template: %w", err)
+		return fmt.Errorf("error executing template %s for %s: %w", name, page.source(), p.loader.Diagnose(err))
 	}
 
-	relativePath, err := filepath.Rel(p.config.PagesDir, file)
-	if err != nil {
-		return fmt.Errorf("failed to get relative path: %w", err)
+	output := parsedTemplateBuf.String()
+	switch format.Name {
+	case "html":
+		output, err = p.applyFilters(config.FilterPage, page, output)
+		if err != nil {
+			return err
+		}
+	case "ics":
+		// iCalendar lines end in CRLF.
+		output = strings.ReplaceAll(strings.ReplaceAll(output, "\r\n", "\n"), "\n", "\r\n")
 	}
-	err = p.writeTemplate(relativePath, parsedTemplateBuf.String())
+
+	err = p.writeTemplate(format.OutputPath, output)
 	if err != nil {
 		return fmt.Errorf("error writing template: %w", err)
 	}
@@ -94,14 +218,37 @@ func (p *PageProcessor) ProcessPage(file string) error {
 	return nil
 }
 
-func (p *PageProcessor) writeTemplate(name string, content string) error {
-	err := os.MkdirAll(p.config.PublicDir+"/"+filepath.Dir(name), os.ModePerm)
+// templateFuncs are the functions available to layouts and .tmpl pages.
+func (p *PageProcessor) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"ref": func(reference string) (string, error) {
+			return p.absRef(nil, reference)
+		},
+		"relref": func(reference string) (string, error) {
+			return p.ref(nil, reference)
+		},
+		"T":             p.translate,
+		"absURL":        p.config.AbsURL,
+		"partial":       p.partial,
+		"partialCached": p.partialCached,
+	}
+}
+
+func (p *PageProcessor) sourcePath(file string) string {
+	relativePath, err := filepath.Rel(p.config.PagesDir, file)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(relativePath)
+}
+
+func (p *PageProcessor) writeTemplate(outputPath string, content string) error {
+	err := os.MkdirAll(filepath.Join(p.config.PublicDir, filepath.Dir(outputPath)), os.ModePerm)
 	if err != nil {
 		return err
 	}
 
-	newFileName := strings.Replace(name, filepath.Ext(name), ".html", 1)
-	file, err := os.Create(p.config.PublicDir + "/" + newFileName)
+	file, err := os.Create(filepath.Join(p.config.PublicDir, outputPath))
 	if err != nil {
 		return err
 	}
@@ -115,33 +262,60 @@ func (p *PageProcessor) writeTemplate(name string, content string) error {
 	return nil
 }
 
-func ProcessAssets(srcDir string, dstDir string, verbose bool) error {
-	cssProcessor := assets.NewCSSProcessor(srcDir, dstDir, verbose)
-	
-	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// ProcessAssets copies the files of srcDirs to dstDir, processing CSS on the
+// way. The directories are layered: a file overrides the files with the same
+// relative path in later directories, so a site's assets override its
+// themes'. Directories that don't exist are skipped.
+func ProcessAssets(srcDirs []string, dstDir string, verbose bool) error {
+	// sources are the directories the winning files are in.
+	sources := map[string]string{}
+	var relPaths []string
+	for _, srcDir := range srcDirs {
+		if _, err := os.Stat(srcDir); os.IsNotExist(err) {
+			continue
 		}
 
-		relPath, err := filepath.Rel(srcDir, path)
+		err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(srcDir, path)
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return os.MkdirAll(filepath.Join(dstDir, relPath), info.Mode())
+			}
+
+			if _, ok := sources[relPath]; !ok {
+				sources[relPath] = srcDir
+				relPaths = append(relPaths, relPath)
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
+	}
 
-		dstPath := filepath.Join(dstDir, relPath)
-
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
-		}
+	for _, relPath := range relPaths {
+		srcDir := sources[relPath]
+		path, dstPath := filepath.Join(srcDir, relPath), filepath.Join(dstDir, relPath)
 
+		var err error
 		if strings.HasSuffix(path, ".css") {
-			return cssProcessor.ProcessTailwind(path, dstPath)
+			err = assets.NewCSSProcessor(srcDir, dstDir, verbose).ProcessTailwind(path, dstPath)
+		} else {
+			err = copyFile(path, dstPath)
 		}
+		if err != nil {
+			return err
+		}
+	}
 
-		return copyFile(path, dstPath)
-	})
-
-	return err
+	return nil
 }
 
 func copyFile(src, dst string) error {
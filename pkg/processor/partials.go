@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// PartialStats counts how often partialCached rendered a template and how
+// often it reused an earlier rendering.
+type PartialStats struct {
+	Name   string
+	Hits   int
+	Misses int
+}
+
+// HitRate returns the share of calls served from the cache, from 0 to 1.
+func (s PartialStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// partial implements the partial template function: it executes the named
+// template with context and returns its output, so it can be piped or
+// assigned.
+func (p *PageProcessor) partial(name string, context interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := p.templates.ExecuteTemplate(&buf, name, context); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// partialCached implements the partialCached template function: like
+// partial, but the template is executed once per build for each combination
+// of variant keys, and later calls reuse that output whatever their context.
+// Without variants, a template renders once for the whole site:
+//
+//	{{ partialCached "footer" . }}
+//	{{ partialCached "sidebar" . .Page.Language.Code }}
+func (p *PageProcessor) partialCached(name string, context interface{}, variants ...interface{}) (string, error) {
+	key := name
+	for _, variant := range variants {
+		key += "\x00" + fmt.Sprint(variant)
+	}
+
+	stats := p.partialStats[name]
+	if stats == nil {
+		stats = &PartialStats{Name: name}
+		p.partialStats[name] = stats
+	}
+
+	if output, ok := p.partialCache[key]; ok {
+		stats.Hits++
+		return output, nil
+	}
+	stats.Misses++
+
+	output, err := p.partial(name, context)
+	if err != nil {
+		return "", err
+	}
+	p.partialCache[key] = output
+	return output, nil
+}
+
+// PartialStats returns the cache statistics of every template rendered
+// with partialCached, by name.
+func (p *PageProcessor) PartialStats() []PartialStats {
+	stats := make([]PartialStats, 0, len(p.partialStats))
+	for _, s := range p.partialStats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
@@ -0,0 +1,169 @@
+package processor
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Redirect leads from an old URL of a page, one of its aliases, to the
+// page's permalink.
+type Redirect struct {
+	// From is the site-relative URL of the alias, including the base path.
+	From string
+	// To is the permalink of the page.
+	To string
+	// OutputPath is the redirect page written for the alias, relative to
+	// PublicDir.
+	OutputPath string
+	Page       *Page
+}
+
+// indexRedirects collects the aliases frontmatter of every page: a list of
+// old URL paths, relative to the site root of the page's language, that
+// should redirect to the page. Aliases that would overwrite a page or
+// another alias are skipped with a warning. When the default language has a
+// prefix, the site root redirects to its home page.
+func (p *PageProcessor) indexRedirects(site *Site) {
+	taken := map[string]string{}
+	for _, page := range site.Pages {
+		taken[page.OutputPath] = page.file
+	}
+
+	site.redirects = nil
+	for _, page := range site.Pages {
+		var aliases []string
+		switch v := page.frontMatter["aliases"].(type) {
+		case nil:
+		case string:
+			aliases = []string{v}
+		case []interface{}:
+			for _, alias := range v {
+				aliases = append(aliases, fmt.Sprint(alias))
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: %s: aliases must be a list of paths, not %T\n", page.file, v)
+		}
+
+		for _, alias := range aliases {
+			redirect, ok := p.aliasRedirect(page, alias)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Warning: %s: invalid alias %q\n", page.file, alias)
+				continue
+			}
+			if other, ok := taken[redirect.OutputPath]; ok {
+				fmt.Fprintf(os.Stderr, "Warning: %s: alias %q would overwrite %s\n", page.file, alias, other)
+				continue
+			}
+			taken[redirect.OutputPath] = page.file
+			page.Aliases = append(page.Aliases, redirect.From)
+			site.redirects = append(site.redirects, redirect)
+		}
+	}
+
+	// With the default language under a prefix, the site root leads to its
+	// home page.
+	if _, ok := taken["index.html"]; ok || site.defaultLanguage.Prefix == "" {
+		return
+	}
+	for _, page := range site.Pages {
+		if page.Language == site.defaultLanguage && isIndexPage(page) && path.Dir(page.key) == "." {
+			site.redirects = append(site.redirects, Redirect{
+				From:       p.config.BasePath() + "/",
+				To:         page.Permalink,
+				OutputPath: "index.html",
+				Page:       page,
+			})
+			return
+		}
+	}
+}
+
+// aliasRedirect returns the redirect for one alias of page. An alias with a
+// file extension, such as "/old/about.html", is written as that file; any
+// other alias as a directory with an index.html.
+func (p *PageProcessor) aliasRedirect(page *Page, alias string) (Redirect, bool) {
+	alias = strings.TrimSpace(alias)
+	if alias == "" || strings.Contains(alias, "://") {
+		return Redirect{}, false
+	}
+	cleaned := path.Clean("/" + alias)
+	if cleaned == "/" {
+		return Redirect{}, false
+	}
+
+	prefix := page.Language.Prefix
+	redirect := Redirect{To: page.Permalink, Page: page}
+	if path.Ext(cleaned) == "" || strings.HasSuffix(alias, "/") {
+		redirect.OutputPath = strings.TrimPrefix(path.Join(prefix, cleaned, "index.html"), "/")
+		redirect.From = p.config.BasePath() + prefix + cleaned + "/"
+	} else {
+		redirect.OutputPath = strings.TrimPrefix(path.Join(prefix, cleaned), "/")
+		redirect.From = p.config.BasePath() + prefix + cleaned
+	}
+	return redirect, true
+}
+
+// Redirects returns the redirects from the aliases of every page, in page
+// order.
+func (p *PageProcessor) Redirects() ([]Redirect, error) {
+	if p.site == nil {
+		if err := p.IndexPages(); err != nil {
+			return nil, err
+		}
+	}
+	return p.site.redirects, nil
+}
+
+// writeRedirects writes a redirect page for every alias and, when enabled in
+// the config, the same redirects as a Netlify _redirects file and as
+// entries for an nginx map block.
+func (p *PageProcessor) writeRedirects() error {
+	var netlify, nginx strings.Builder
+	for _, redirect := range p.site.redirects {
+		if err := p.writeTemplate(redirect.OutputPath, p.redirectPage(redirect)); err != nil {
+			return fmt.Errorf("error writing redirect page: %w", err)
+		}
+
+		fmt.Fprintf(&netlify, "%s %s 301\n", redirect.From, redirect.To)
+		fmt.Fprintf(&nginx, "%s %s;\n", redirect.From, redirect.To)
+		if trimmed := strings.TrimSuffix(redirect.From, "/"); trimmed != redirect.From && trimmed != "" {
+			fmt.Fprintf(&nginx, "%s %s;\n", trimmed, redirect.To)
+		}
+	}
+
+	if p.config.Redirects.Netlify {
+		if err := os.WriteFile(filepath.Join(p.config.PublicDir, "_redirects"), []byte(netlify.String()), 0644); err != nil {
+			return fmt.Errorf("error writing _redirects: %w", err)
+		}
+	}
+	if p.config.Redirects.Nginx {
+		if err := os.WriteFile(filepath.Join(p.config.PublicDir, "redirects.map"), []byte(nginx.String()), 0644); err != nil {
+			return fmt.Errorf("error writing redirects.map: %w", err)
+		}
+	}
+	return nil
+}
+
+// redirectPage is the HTML written at an alias: a meta refresh to the page,
+// which search engines also see as its canonical URL.
+func (p *PageProcessor) redirectPage(redirect Redirect) string {
+	target := html.EscapeString(p.config.AbsURL(redirect.To))
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <title>%s</title>
+    <link rel="canonical" href="%s">
+    <meta name="robots" content="noindex">
+    <meta charset="utf-8">
+    <meta http-equiv="refresh" content="0; url=%s">
+</head>
+<body>
+    <p>This page has moved to <a href="%s">%s</a>.</p>
+</body>
+</html>
+`, target, target, target, target, target)
+}
@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Resource is a file under PagesDir that isn't a page, such as an image next
+// to the page that uses it. Resources are copied to the same relative path
+// under PublicDir.
+type Resource struct {
+	// Name is the path relative to the directory of the bundle's index page,
+	// slash separated, e.g. "images/cover.jpg".
+	Name string
+	// Permalink is the site-relative URL of the copied file.
+	Permalink string
+	// MediaType is the MIME type guessed from the file extension.
+	MediaType string
+
+	file string
+}
+
+// Resources are the resources of a page bundle, sorted by name.
+type Resources []*Resource
+
+// Get returns the resource with the given name, or nil.
+func (r Resources) Get(name string) *Resource {
+	for _, resource := range r {
+		if resource.Name == name {
+			return resource
+		}
+	}
+	return nil
+}
+
+// Match returns the resources whose names match a path.Match glob such as
+// "images/*.jpg".
+func (r Resources) Match(pattern string) Resources {
+	var matches Resources
+	for _, resource := range r {
+		if ok, _ := path.Match(pattern, resource.Name); ok {
+			matches = append(matches, resource)
+		}
+	}
+	return matches
+}
+
+// GetMatch returns the first resource matching a glob, or nil.
+func (r Resources) GetMatch(pattern string) *Resource {
+	if matches := r.Match(pattern); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+// ByType returns the resources whose media type starts with mediaType, such
+// as "image".
+func (r Resources) ByType(mediaType string) Resources {
+	var matches Resources
+	for _, resource := range r {
+		if strings.HasPrefix(resource.MediaType, mediaType) {
+			matches = append(matches, resource)
+		}
+	}
+	return matches
+}
+
+// addResource indexes a non-page file under PagesDir as a resource of the
+// closest page bundle above it in the language of site: the directory of an
+// index page, such as pages/post/index.md. Files in a language directory
+// only belong to that language.
+func (p *PageProcessor) addResource(site *Site, file string) {
+	language, key := site.splitLanguage(p.sourcePath(file))
+	if key != p.sourcePath(file) && language != site.Language {
+		return
+	}
+
+	resource := &Resource{
+		Permalink: p.config.BasePath() + site.Language.Prefix + "/" + (&url.URL{Path: key}).EscapedPath(),
+		MediaType: mime.TypeByExtension(path.Ext(key)),
+		file:      file,
+	}
+
+	for dir := path.Dir(key); ; dir = path.Dir(dir) {
+		if bundle := site.bundle(dir); bundle != nil {
+			resource.Name = strings.TrimPrefix(key, strings.TrimPrefix(dir+"/", "./"))
+			bundle.Resources = append(bundle.Resources, resource)
+			break
+		}
+		if dir == "." {
+			break
+		}
+	}
+}
+
+// bundle returns the index page of a directory relative to PagesDir, or nil.
+func (s *Site) bundle(dir string) *Page {
+	for _, page := range s.Pages {
+		if path.Dir(page.key) == dir && isIndexPage(page) {
+			return page
+		}
+	}
+	return nil
+}
+
+func sortResources(site *Site) {
+	for _, page := range site.Pages {
+		sort.Slice(page.Resources, func(i, j int) bool {
+			return page.Resources[i].Name < page.Resources[j].Name
+		})
+	}
+}
+
+// copyResource copies a non-page file under PagesDir to the same relative
+// path under PublicDir, so relative links from its bundle's page keep
+// working. On multilingual sites, it is copied into the directory of each
+// language, or only of the language whose directory it is in.
+func (p *PageProcessor) copyResource(file string) error {
+	language, key := p.site.splitLanguage(p.sourcePath(file))
+	languages := p.site.Languages
+	if key != p.sourcePath(file) {
+		languages = []*Language{language}
+	}
+
+	for _, language := range languages {
+		dst := filepath.Join(p.config.PublicDir, filepath.FromSlash(strings.TrimPrefix(language.Prefix, "/")), filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return err
+		}
+		if err := copyFile(file, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// shortcodeFunc renders a shortcode used in a Markdown page. Positional
+// arguments are in args, key="value" arguments in params.
+type shortcodeFunc func(ctx *shortcodeContext, args []string, params map[string]string) (string, error)
+
+// shortcodeContext describes where a shortcode is expanded.
+type shortcodeContext struct {
+	page *Page
+	// file is the source being expanded: the page itself or an included
+	// fragment.
+	file string
+	// includes is the chain of files included so far, to detect cycles.
+	includes []string
+}
+
+var (
+	shortcodePattern = regexp.MustCompile(`\{\{<\s*(/\*)?\s*([A-Za-z][\w-]*)((?:\s+[^>]*?)?)\s*(\*/)?\s*>\}\}`)
+	shortcodeArg     = regexp.MustCompile(`(?:([A-Za-z][\w-]*)=)?(?:"((?:[^"\\]|\\.)*)"|(\S+))`)
+)
+
+func (p *PageProcessor) shortcodes() map[string]shortcodeFunc {
+	return map[string]shortcodeFunc{
+		"ref": func(ctx *shortcodeContext, args []string, params map[string]string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("ref expects one page argument")
+			}
+			return p.absRef(ctx.page, args[0])
+		},
+		"relref": func(ctx *shortcodeContext, args []string, params map[string]string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("relref expects one page argument")
+			}
+			return p.ref(ctx.page, args[0])
+		},
+		"include": p.include,
+	}
+}
+
+// expandShortcodes replaces every {{< name args >}} in a Markdown source with
+// the shortcode's output. {{</* name */>}} is kept literally, without the
+// comment markers, so shortcodes can be documented.
+func (p *PageProcessor) expandShortcodes(ctx *shortcodeContext, source string) (string, error) {
+	shortcodes := p.shortcodes()
+
+	var firstErr error
+	expanded := shortcodePattern.ReplaceAllStringFunc(source, func(match string) string {
+		if firstErr != nil {
+			return match
+		}
+
+		m := shortcodePattern.FindStringSubmatch(match)
+		if m[1] != "" && m[4] != "" {
+			return "{{< " + m[2] + m[3] + " >}}"
+		}
+
+		shortcode, ok := shortcodes[m[2]]
+		if !ok {
+			firstErr = fmt.Errorf("unknown shortcode %q", m[2])
+			return match
+		}
+
+		args, params := parseShortcodeArgs(m[3])
+		out, err := shortcode(ctx, args, params)
+		if err != nil {
+			firstErr = fmt.Errorf("shortcode %q: %w", m[2], err)
+			return match
+		}
+		return out
+	})
+	if firstErr != nil {
+		return "", fmt.Errorf("%s: %w", ctx.file, firstErr)
+	}
+
+	return expanded, nil
+}
+
+func parseShortcodeArgs(raw string) ([]string, map[string]string) {
+	var args []string
+	params := map[string]string{}
+
+	for _, m := range shortcodeArg.FindAllStringSubmatch(raw, -1) {
+		value := m[3]
+		if m[3] == "" {
+			value = strings.ReplaceAll(m[2], `\"`, `"`)
+		}
+		if m[1] != "" {
+			params[m[1]] = value
+		} else {
+			args = append(args, value)
+		}
+	}
+
+	return args, params
+}
@@ -0,0 +1,338 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sitetext "github.com/ahoglund/go-static/pkg/text"
+	"gopkg.in/yaml.v3"
+)
+
+// Page is the indexed form of a source file under PagesDir.
+type Page struct {
+	Title string
+	// Path is the source path relative to PagesDir, slash separated.
+	Path string
+	// Permalink is the site-relative URL of the rendered page, including
+	// the base path.
+	Permalink string
+	// OutputPath is the rendered file relative to PublicDir.
+	OutputPath string
+	// Backlinks are the pages linking to this one, sorted by title.
+	Backlinks []*Page
+	// Resources are the non-page files of the page's bundle, when the page is
+	// the index page of its directory.
+	Resources Resources
+	// Ancestors are the index pages of the directories above the page,
+	// starting at the site root, for breadcrumbs.
+	Ancestors []*Page
+	// PrevInSection and NextInSection are the neighbours of the page among
+	// the other pages in its directory, ordered by weight, then date.
+	PrevInSection *Page
+	NextInSection *Page
+	// Related are the pages sharing the most tags or other configured
+	// frontmatter values with the page.
+	Related []*Page
+	// Language is the language the page is written in.
+	Language *Language
+	// Translations are the versions of the page in the other languages,
+	// ordered by language weight.
+	Translations []*Page
+	// Aliases are the site-relative URLs that redirect to the page, from
+	// its aliases frontmatter.
+	Aliases []string
+	// Lastmod is when the page was last changed: its lastmod frontmatter,
+	// else the date of its last commit with git info enabled, else its date.
+	Lastmod time.Time
+	// GitInfo is the last commit of the page's source file, or nil without
+	// git info.
+	GitInfo *GitInfo
+	// EditURL links to the page's source file in the repository at
+	// config's editURL, or is empty.
+	EditURL string
+	// OutputFormats are the files the page is rendered to, the first at
+	// OutputPath and Permalink.
+	OutputFormats []*OutputFormat
+
+	// key is the source path without language directory or suffix, which
+	// the page shares with its translations.
+	key         string
+	slug        string
+	file        string
+	frontMatter map[interface{}]interface{}
+	content     string
+	// section is set for index pages whose directory holds other pages;
+	// other index pages are leaf bundles, rendered as single pages.
+	section bool
+}
+
+// Site is the index of every page under PagesDir. It is built before any
+// page is rendered so that pages can link to each other.
+type Site struct {
+	Pages []*Page
+
+	// Menus are the navigation menus by name, e.g. .Site.Menus.main.
+	Menus map[string]Menu
+
+	// Data is the content of the YAML and JSON files under data/, e.g.
+	// .Site.Data.authors for data/authors.yaml.
+	Data map[string]interface{}
+
+	// Language is the language of the pages in this view of the site, and
+	// Languages are all languages the site is published in.
+	Language  *Language
+	Languages []*Language
+
+	byPath map[string]*Page
+	// stubs are generated for unresolved wiki links, keyed by slug.
+	stubs           map[string]*Page
+	defaultLanguage *Language
+	// languageSites are the views of the site in each language.
+	languageSites map[*Language]*Site
+	// redirects lead from page aliases to the pages.
+	redirects []Redirect
+	// gitInfo is the last commit of every source file by absolute path, when
+	// git info is enabled.
+	gitInfo map[string]*GitInfo
+}
+
+// GetPage returns the page whose source path relative to PagesDir is
+// sourcePath, or nil.
+func (s *Site) GetPage(sourcePath string) *Page {
+	return s.byPath[strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(sourcePath)), "/")]
+}
+
+// IndexPages reads the frontmatter of every page under PagesDir, computes
+// its permalink and collects the links between pages. ProcessPage indexes
+// lazily if this hasn't been called.
+func (p *PageProcessor) IndexPages() error {
+	site := &Site{byPath: map[string]*Page{}}
+	site.Languages, site.defaultLanguage = p.languages()
+	site.Language = site.defaultLanguage
+	if err := p.loadTranslations(site.Languages); err != nil {
+		return err
+	}
+	p.indexGitInfo(site)
+	data, err := p.loadData()
+	if err != nil {
+		return err
+	}
+	site.Data = data
+	var resources []string
+
+	err = filepath.WalkDir(p.config.PagesDir, func(file string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		if !p.isPageFile(file) {
+			resources = append(resources, file)
+			return nil
+		}
+
+		page, err := p.loadPage(site, file)
+		if err != nil {
+			return err
+		}
+		site.Pages = append(site.Pages, page)
+		site.byPath[page.Path] = page
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index pages: %w", err)
+	}
+
+	site.indexLanguages()
+	p.indexRedirects(site)
+	for _, language := range site.Languages {
+		view := site.languageSite(language)
+		for _, file := range resources {
+			p.addResource(view, file)
+		}
+		sortResources(view)
+		if err := p.buildMenus(view); err != nil {
+			return fmt.Errorf("failed to build menus: %w", err)
+		}
+		p.indexNavigation(view)
+	}
+
+	p.site = site
+	p.indexLinks()
+	return nil
+}
+
+func (p *PageProcessor) loadPage(site *Site, file string) (*Page, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+	}
+
+	var y map[interface{}]interface{}
+	var rawContent string
+	if reader, ok := p.converter(file).(FrontMatterReader); ok {
+		y, rawContent, err = reader.ReadFrontMatter(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("error reading frontmatter in file %s: %w", file, err)
+		}
+	} else {
+		data := strings.Split(string(content), FrontMatterDelimiter)
+		if len(data) < 3 {
+			return nil, fmt.Errorf("invalid frontmatter format in file %s", file)
+		}
+
+		rawFrontMatter := data[1]
+		rawContent = data[2]
+
+		err = yaml.Unmarshal([]byte(rawFrontMatter), &y)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing YAML in file %s: %w", file, err)
+		}
+	}
+	if y == nil {
+		y = map[interface{}]interface{}{}
+	}
+
+	if _, ok := y["title"]; !ok {
+		return nil, fmt.Errorf("file %s doesn't contain a title", file)
+	}
+
+	relativePath, err := filepath.Rel(p.config.PagesDir, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	page := &Page{
+		Title:       fmt.Sprint(y["title"]),
+		Path:        filepath.ToSlash(relativePath),
+		file:        file,
+		frontMatter: y,
+		content:     rawContent,
+	}
+
+	page.Language, page.key = site.splitLanguage(page.Path)
+
+	page.GitInfo = site.pageGitInfo(file)
+	page.Lastmod = dateValue(y["lastmod"])
+	if page.Lastmod.IsZero() && page.GitInfo != nil {
+		page.Lastmod = page.GitInfo.AuthorDate
+	}
+	if page.Lastmod.IsZero() {
+		page.Lastmod = dateValue(y["date"])
+	}
+	if p.config.EditURL != "" {
+		page.EditURL = p.editURL(file)
+	}
+
+	if isIndexPage(page) && path.Dir(page.key) != "." {
+		page.section = p.hasChildPages(file)
+	}
+
+	slug, _ := y["slug"].(string)
+	page.OutputPath, page.Permalink = p.permalink(page.key, slug, page.Language)
+	outputs, err := pageOutputs(p.config, page)
+	if err != nil {
+		return nil, fmt.Errorf("error in file %s: %w", file, err)
+	}
+	page.OutputFormats = outputFormats(page, outputs)
+
+	page.slug = sitetext.Slugify(slug)
+	if page.slug == "" {
+		page.slug = sitetext.Slugify(strings.TrimSuffix(path.Base(page.key), path.Ext(page.key)))
+	}
+
+	return page, nil
+}
+
+// errChildPage stops the walk of hasChildPages at the first page found.
+var errChildPage = errors.New("child page")
+
+// hasChildPages reports whether the directory of an index page file holds
+// other pages, directly or in its subdirectories. Index pages of the same
+// directory in other languages don't count.
+func (p *PageProcessor) hasChildPages(file string) bool {
+	dir := filepath.Dir(file)
+	err := filepath.WalkDir(dir, func(f string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") && f != dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !p.isPageFile(f) {
+			return nil
+		}
+		if filepath.Dir(f) == dir && strings.SplitN(info.Name(), ".", 2)[0] == "index" {
+			return nil
+		}
+		return errChildPage
+	})
+	return errors.Is(err, errChildPage)
+}
+
+// permalink maps a source path to its output file and URL. index pages
+// always render to the index.html of their directory; other pages use their
+// slug, or file name, either as name.html or, with pretty URLs, as
+// name/index.html. Both are placed under the language's prefix.
+func (p *PageProcessor) permalink(sourcePath, slug string, language *Language) (outputPath, url string) {
+	dir := path.Dir(sourcePath)
+	if dir == "." {
+		dir = ""
+	}
+	name := strings.TrimSuffix(path.Base(sourcePath), path.Ext(sourcePath))
+
+	var urlPath string
+	switch {
+	case name == "index":
+		outputPath = path.Join(dir, "index.html")
+		urlPath = path.Join("/", dir) + "/"
+	case p.config.PrettyURLs:
+		if slug != "" {
+			name = slug
+		}
+		outputPath = path.Join(dir, name, "index.html")
+		urlPath = path.Join("/", dir, name) + "/"
+	default:
+		if slug != "" {
+			name = slug
+		}
+		outputPath = path.Join(dir, name+".html")
+		urlPath = path.Join("/", dir, name+".html")
+	}
+
+	if urlPath == "//" {
+		urlPath = "/"
+	}
+	return path.Join(strings.TrimPrefix(language.Prefix, "/"), outputPath), p.config.BasePath() + language.Prefix + urlPath
+}
+
+// File returns the page's source file, or "" for generated pages.
+func (p *Page) File() string {
+	return p.file
+}
+
+// FrontMatter returns the page's frontmatter as parsed from its source.
+// Layouts are executed with a copy that has the page's content and index
+// data added.
+func (p *Page) FrontMatter() map[interface{}]interface{} {
+	return p.frontMatter
+}
+
+// source names the page in messages: its source file, or its output path
+// for generated pages.
+func (p *Page) source() string {
+	if p.file != "" {
+		return p.file
+	}
+	return p.OutputPath
+}
@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// writeSitemap writes sitemap.xml, listing the HTML file of every page
+// with its Lastmod. Sitemaps need absolute URLs, so none is written when
+// baseURL has no host, and none is written over a sitemap.xml the site
+// provides itself.
+func (p *PageProcessor) writeSitemap() error {
+	if u, err := url.Parse(p.config.BaseURL); err != nil || u.Host == "" {
+		return nil
+	}
+	if p.hasOwnSitemap() {
+		return nil
+	}
+
+	urlset := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, page := range p.site.Pages {
+		for _, format := range page.OutputFormats {
+			if format.Name != "html" {
+				continue
+			}
+			entry := sitemapURL{Loc: p.config.AbsURL(format.Permalink)}
+			if !page.Lastmod.IsZero() {
+				entry.Lastmod = page.Lastmod.Format(time.RFC3339)
+			}
+			urlset.URLs = append(urlset.URLs, entry)
+		}
+	}
+
+	out, err := xml.MarshalIndent(urlset, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding sitemap: %w", err)
+	}
+	out = append([]byte(xml.Header), append(out, '\n')...)
+	if err := os.WriteFile(filepath.Join(p.config.PublicDir, "sitemap.xml"), out, 0644); err != nil {
+		return fmt.Errorf("error writing sitemap.xml: %w", err)
+	}
+	return nil
+}
+
+// hasOwnSitemap reports whether sitemap.xml is a file under PagesDir or an
+// assets directory, or the output of a page.
+func (p *PageProcessor) hasOwnSitemap() bool {
+	for _, dir := range append([]string{p.config.PagesDir}, p.config.AssetsDirs()...) {
+		if _, err := os.Stat(filepath.Join(dir, "sitemap.xml")); err == nil {
+			return true
+		}
+	}
+	for _, page := range p.site.Pages {
+		for _, format := range page.OutputFormats {
+			if format.OutputPath == "sitemap.xml" {
+				return true
+			}
+		}
+	}
+	return false
+}
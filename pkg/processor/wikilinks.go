@@ -0,0 +1,252 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ahoglund/go-static/pkg/config"
+	sitetext "github.com/ahoglund/go-static/pkg/text"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// wikiLinkPattern matches [[target]] and [[target|label]].
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|([^\[\]\n]+))?\]\]`)
+
+// StubContent is the body of pages generated for unresolved wiki links.
+const StubContent = "<p>This page hasn't been written yet.</p>\n"
+
+// resolveWikiLink finds the page a [[target]] refers to, by source path
+// (with or without extension), then title, then slug.
+func (s *Site) resolveWikiLink(target string) *Page {
+	target = strings.TrimSpace(target)
+	if i := strings.Index(target, "#"); i >= 0 {
+		target = target[:i]
+	}
+
+	if page := s.GetPage(target); page != nil {
+		return page
+	}
+	for _, page := range s.Pages {
+		if strings.TrimSuffix(page.Path, path.Ext(page.Path)) == strings.TrimPrefix(target, "/") {
+			return page
+		}
+	}
+	for _, page := range s.Pages {
+		if strings.EqualFold(page.Title, target) {
+			return page
+		}
+	}
+	slug := sitetext.Slugify(target)
+	for _, page := range s.Pages {
+		if page.slug == slug {
+			return page
+		}
+	}
+	return nil
+}
+
+// rewriteWikiLinks replaces every [[target|label]] in the text of doc with a
+// link to the page resolved for target. Unresolved links are handled as
+// configured in wikiLinks.unresolved.
+func (p *PageProcessor) rewriteWikiLinks(page *Page, doc ast.Node) error {
+	var texts []*ast.Text
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if text, ok := node.(*ast.Text); ok && wikiLinkPattern.Match(text.Literal) {
+			texts = append(texts, text)
+		}
+		return ast.GoToNext
+	})
+
+	for _, text := range texts {
+		var nodes []ast.Node
+		literal, last := text.Literal, 0
+		for _, m := range wikiLinkPattern.FindAllSubmatchIndex(literal, -1) {
+			target := string(literal[m[2]:m[3]])
+			label := target
+			if m[4] >= 0 {
+				label = string(literal[m[4]:m[5]])
+			}
+
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: literal[last:m[0]]}})
+			link, err := p.wikiLink(page, target, label)
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, link)
+			last = m[1]
+		}
+		nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: literal[last:]}})
+
+		replace(text, nodes...)
+	}
+
+	return nil
+}
+
+func (p *PageProcessor) wikiLink(page *Page, target, label string) (ast.Node, error) {
+	labelNode := &ast.Text{Leaf: ast.Leaf{Literal: []byte(label)}}
+
+	fragment := ""
+	if i := strings.Index(target, "#"); i >= 0 {
+		fragment = target[i:]
+	}
+
+	linked := p.site.resolveWikiLink(target)
+	if translation := linked.translation(page.Language); translation != nil {
+		linked = translation
+	}
+	if linked == nil {
+		switch p.config.WikiLinks.Unresolved {
+		case config.WikiLinksError:
+			return nil, fmt.Errorf("%s: unresolved wiki link [[%s]]", page.file, target)
+		case config.WikiLinksWarn:
+			fmt.Fprintf(os.Stderr, "Warning: %s: unresolved wiki link [[%s]]\n", page.file, target)
+			return labelNode, nil
+		}
+		linked = p.stubPage(target)
+	}
+
+	link := &ast.Link{Destination: []byte(linked.Permalink + fragment)}
+	if linked.file == "" {
+		link.AdditionalAttributes = []string{`class="wikilink-stub"`}
+	}
+	adopt(link, labelNode)
+	return link, nil
+}
+
+// indexLinks records, for every Markdown page, which pages it links to via
+// wiki links or links to source files, and fills in the Backlinks of the
+// targets. With wikiLinks.unresolved set to "stub", a stub page is added to
+// the site for each unresolved wiki link target.
+func (p *PageProcessor) indexLinks() {
+	site := p.site
+	site.stubs = map[string]*Page{}
+
+	linked := map[*Page]map[*Page]bool{}
+	addLink := func(from, to *Page) {
+		if from == to {
+			return
+		}
+		if linked[to] == nil {
+			linked[to] = map[*Page]bool{}
+		}
+		if !linked[to][from] {
+			linked[to][from] = true
+			to.Backlinks = append(to.Backlinks, from)
+		}
+	}
+
+	for _, page := range site.Pages {
+		if !isMarkdownPage(page) {
+			continue
+		}
+
+		doc := parseMarkdown([]byte(page.content))
+		ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+			if !entering {
+				return ast.GoToNext
+			}
+			switch node := node.(type) {
+			case *ast.Link:
+				if target := p.linkedPage(page, string(node.Destination)); target != nil {
+					addLink(page, target)
+				}
+			case *ast.Text:
+				for _, m := range wikiLinkPattern.FindAllSubmatch(node.Literal, -1) {
+					target := site.resolveWikiLink(string(m[1]))
+					if translation := target.translation(page.Language); translation != nil {
+						target = translation
+					}
+					if target == nil && p.config.WikiLinks.Unresolved == config.WikiLinksStub {
+						target = p.stubPage(string(m[1]))
+					}
+					if target != nil {
+						addLink(page, target)
+					}
+				}
+			}
+			return ast.GoToNext
+		})
+	}
+
+	for _, page := range site.Pages {
+		sort.Slice(page.Backlinks, func(i, j int) bool {
+			return page.Backlinks[i].Title < page.Backlinks[j].Title
+		})
+	}
+	for _, stub := range site.stubs {
+		sort.Slice(stub.Backlinks, func(i, j int) bool {
+			return stub.Backlinks[i].Title < stub.Backlinks[j].Title
+		})
+	}
+}
+
+// stubPage returns the stub generated for an unresolved wiki link target,
+// creating it on first use. Stubs live at the site root, named after the
+// slug of the target.
+func (p *PageProcessor) stubPage(target string) *Page {
+	target = strings.TrimSpace(strings.SplitN(target, "#", 2)[0])
+	slug := sitetext.Slugify(target)
+	if stub, ok := p.site.stubs[slug]; ok {
+		return stub
+	}
+
+	stub := &Page{
+		Title:    target,
+		Language: p.site.defaultLanguage,
+		key:      slug + ".md",
+		slug:     slug,
+		frontMatter: map[interface{}]interface{}{
+			"title": target,
+			"stub":  true,
+		},
+	}
+	stub.OutputPath, stub.Permalink = p.permalink(stub.key, "", stub.Language)
+	stub.OutputFormats = outputFormats(stub, []string{"html"})
+	p.site.stubs[slug] = stub
+	return stub
+}
+
+// ProcessGeneratedPages renders the pages that have no source file of their
+// own, such as stubs for unresolved wiki links, redirects from page aliases,
+// the sitemap, llms.txt and the Gemini capsule. Call it after every source
+// page has been processed.
+func (p *PageProcessor) ProcessGeneratedPages() error {
+	if p.site == nil {
+		if err := p.IndexPages(); err != nil {
+			return err
+		}
+	}
+
+	slugs := make([]string, 0, len(p.site.stubs))
+	for slug := range p.site.stubs {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	for _, slug := range slugs {
+		if err := p.renderPage(p.site.stubs[slug], StubContent); err != nil {
+			return fmt.Errorf("error rendering stub page for [[%s]]: %w", p.site.stubs[slug].Title, err)
+		}
+	}
+
+	if err := p.writeRedirects(); err != nil {
+		return err
+	}
+	if err := p.writeSitemap(); err != nil {
+		return err
+	}
+	if p.config.LLMs.Enabled {
+		if err := p.writeLLMs(); err != nil {
+			return err
+		}
+	}
+	if p.config.Gemini.Enabled {
+		return p.writeGemini()
+	}
+	return nil
+}
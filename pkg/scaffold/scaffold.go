@@ -9,7 +9,7 @@ import (
 	"strings"
 )
 
-//go:embed templates/site/*
+//go:embed templates/site/* templates/site/templates/_default
 var siteTemplates embed.FS
 
 //go:embed templates/github/*
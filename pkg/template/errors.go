@@ -0,0 +1,149 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// snippetLines is the number of lines shown before and after the line a
+// template error points to.
+const snippetLines = 2
+
+// Error is a template parse or execution error with the place in the
+// template file it points to.
+type Error struct {
+	// Name is the template the error is in, e.g. "blog/single.tmpl".
+	Name string
+	// File is the path of the template file, or "" if it isn't known.
+	File string
+	Line int
+	// Column counts bytes from 1, or is 0 if the error has no column.
+	Column int
+	// Message is the error without its location.
+	Message string
+	// Key is the map key that was missing, if that was the error.
+	Key string
+	// Snippet is the numbered source lines around Line.
+	Snippet string
+
+	err error
+}
+
+var (
+	errorLocation = regexp.MustCompile(`(?s)^template: ([^:]+):(\d+)(?::(\d+))?: (.*)$`)
+	missingKey    = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+)
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	location := e.Name
+	if e.File != "" {
+		location = e.File
+	}
+	fmt.Fprintf(&b, "%s:%d", location, e.Line)
+	if e.Column > 0 {
+		fmt.Fprintf(&b, ":%d", e.Column)
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+	if e.Key != "" {
+		fmt.Fprintf(&b, "\n  missing key %q", e.Key)
+	}
+	if e.Snippet != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Snippet)
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Diagnose turns a text/template parse or execution error into an *Error
+// with the file, line and column it points to and the source lines around
+// them. Other errors, and errors that are already diagnosed, are returned
+// unchanged.
+func (t *TemplateLoader) Diagnose(err error) error {
+	var diagnosed *Error
+	if err == nil || errors.As(err, &diagnosed) {
+		return err
+	}
+
+	m := errorLocation.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	e := &Error{Name: m[1], Message: m[4], err: err}
+	e.Line, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		// text/template counts columns from 0.
+		column, _ := strconv.Atoi(m[3])
+		e.Column = column + 1
+	}
+	if k := missingKey.FindStringSubmatch(e.Message); k != nil {
+		e.Key = k[1]
+	}
+
+	e.File = t.templatePath(e.Name)
+	if e.File == "" {
+		if _, err := os.Stat(e.Name); err == nil {
+			// Templates of .tmpl pages are named by their file.
+			e.File = e.Name
+		}
+	}
+	if e.File != "" {
+		if content, err := os.ReadFile(e.File); err == nil {
+			e.Snippet = snippet(string(content), e.Line, e.Column)
+		}
+	}
+	return e
+}
+
+// snippet returns the lines around line, numbered, with the line marked and
+// a caret under column when it is known.
+func snippet(source string, line, column int) string {
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	first, last := line-snippetLines, line+snippetLines
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last))
+
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "  %s %*d | %s\n", marker, width, n, strings.TrimRight(lines[n-1], "\r"))
+		if n == line && column > 0 {
+			fmt.Fprintf(&b, "    %s | %s^\n", strings.Repeat(" ", width), caretIndent(lines[n-1], column))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// caretIndent returns the whitespace that puts a caret under the given
+// 1-based byte column, keeping tabs so it lines up with the source.
+func caretIndent(line string, column int) string {
+	var b strings.Builder
+	for i := 0; i < column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
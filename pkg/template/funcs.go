@@ -0,0 +1,66 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+// FuncMap declares the functions templates may call. Functions that need the
+// page index are placeholders here; the page processor binds the real
+// implementations before executing any template.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"ref":           unbound("ref"),
+		"relref":        unbound("relref"),
+		"T":             unbound("T"),
+		"absURL":        unbound("absURL"),
+		"partial":       unbound("partial"),
+		"partialCached": unbound("partialCached"),
+		"jsonify":       jsonify,
+	}
+}
+
+// jsonify encodes a value as JSON, for layouts of JSON output formats:
+//
+//	{"title": {{ jsonify .title }}}
+func jsonify(v interface{}) (string, error) {
+	encoded, err := json.Marshal(JSONValue(v))
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// JSONValue converts the maps YAML decodes into, which have interface{}
+// keys, to ones encoding/json accepts, so frontmatter can be encoded as
+// JSON.
+func JSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = JSONValue(value)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = JSONValue(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = JSONValue(value)
+		}
+		return s
+	}
+	return v
+}
+
+func unbound(name string) func(...interface{}) (string, error) {
+	return func(...interface{}) (string, error) {
+		return "", fmt.Errorf("%s is only available while building pages", name)
+	}
+}
@@ -1,10 +1,15 @@
 package template
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
+	"text/template/parse"
 
 	"github.com/ahoglund/go-static/pkg/config"
 )
@@ -19,34 +24,249 @@ func NewTemplateLoader(cfg *config.Config) *TemplateLoader {
 	}
 }
 
-func (t *TemplateLoader) LoadTemplates() (*template.Template, error) {
-	templateFiles := []string{}
-	
-	err := filepath.WalkDir(t.config.TemplateDir, func(path string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		
-		if info.IsDir() {
+// TemplateFile is a template file and the name its template is parsed as:
+// its path relative to the templates directory it is in, slash separated.
+type TemplateFile struct {
+	Name string
+	Path string
+}
+
+// TemplateFiles returns the files of the site's templates directory and of
+// its themes' in the order they are parsed, lowest priority first. A file
+// overrides the files with the same name in lower priority directories,
+// which are left out.
+func (t *TemplateLoader) TemplateFiles() ([]TemplateFile, error) {
+	dirs := t.config.TemplateDirs()
+	seen := map[string]bool{}
+	layers := make([][]TemplateFile, len(dirs))
+
+	for i, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			name = filepath.ToSlash(name)
+			if !seen[name] {
+				seen[name] = true
+				layers[i] = append(layers[i], TemplateFile{Name: name, Path: path})
+			}
 			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
 		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk template directory: %w", err)
+		}
+	}
+
+	var files []TemplateFile
+	for i := len(layers) - 1; i >= 0; i-- {
+		files = append(files, layers[i]...)
+	}
+	return files, nil
+}
 
-		templateFiles = append(templateFiles, path)
-		return nil
-	})
-	
+func (t *TemplateLoader) LoadTemplates() (*template.Template, error) {
+	templateFiles, err := t.TemplateFiles()
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk template directory: %w", err)
+		return nil, err
 	}
 
 	if len(templateFiles) == 0 {
 		return nil, fmt.Errorf("no template files found in %s", t.config.TemplateDir)
 	}
 
-	templates, err := template.ParseFiles(templateFiles...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse template files: %w", err)
+	// Name each file's template by its path relative to its templates
+	// directory, such as "blog/single.tmpl", so layouts in different
+	// directories don't clash. Themes are parsed first, so the site's own
+	// {{define}}s win.
+	templates := template.New("").Funcs(FuncMap())
+	if t.config.StrictTemplates {
+		templates.Option("missingkey=error")
+	}
+	for _, file := range templateFiles {
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template file %s: %w", file.Path, err)
+		}
+
+		if _, err := templates.New(file.Name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse template files: %w", t.Diagnose(err))
+		}
 	}
 
 	return templates, nil
 }
+
+// templatePath returns the file a template name is parsed from: the first
+// of the templates directories that has it, or "".
+func (t *TemplateLoader) templatePath(name string) string {
+	for _, dir := range t.config.TemplateDirs() {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// WithBase returns a copy of templates for rendering layout inside base: the
+// base template and then the layout are parsed again from their files, so
+// the blocks the layout defines override the defaults of base, and not those
+// of another layout that defines the same blocks.
+func (t *TemplateLoader) WithBase(templates *template.Template, base, layout string) (*template.Template, error) {
+	set, err := templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{base, layout} {
+		path := t.templatePath(name)
+		if path == "" {
+			return nil, fmt.Errorf("template file %s not found", name)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template file %s: %w", name, err)
+		}
+		if _, err := set.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse template files: %w", t.Diagnose(err))
+		}
+	}
+
+	return set, nil
+}
+
+// IsLayoutOnly reports whether a template has no content of its own outside
+// of the templates it defines, so it can only be rendered inside a base.
+func IsLayoutOnly(tmpl *template.Template) bool {
+	if tmpl == nil || tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return true
+	}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		text, ok := node.(*parse.TextNode)
+		if !ok || strings.TrimSpace(string(text.Text)) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Definition is a template defined in a template file: the file's own
+// template or one of its {{define}}s and {{block}}s.
+type Definition struct {
+	Name string
+	File TemplateFile
+	// IsFile is set for the template named after its file.
+	IsFile bool
+	// Empty is set for a file's template that has no content outside of
+	// its definitions.
+	Empty bool
+	// Calls are the names of the templates it invokes with {{template}},
+	// {{block}}, or partial and partialCached with a constant name.
+	Calls []string
+}
+
+// Definitions parses every template file on its own and returns the
+// templates each defines, in parse order.
+func (t *TemplateLoader) Definitions() ([]Definition, error) {
+	templateFiles, err := t.TemplateFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions []Definition
+	for _, file := range templateFiles {
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template file %s: %w", file.Path, err)
+		}
+		set, err := template.New(file.Name).Funcs(FuncMap()).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template files: %w", t.Diagnose(err))
+		}
+
+		fileTemplate := Definition{Name: file.Name, File: file, IsFile: true, Empty: IsLayoutOnly(set)}
+		if set.Tree != nil {
+			fileTemplate.Calls = calls(set.Tree.Root)
+		}
+		definitions = append(definitions, fileTemplate)
+
+		var defined []Definition
+		for _, tmpl := range set.Templates() {
+			if tmpl.Name() == file.Name || tmpl.Tree == nil {
+				continue
+			}
+			defined = append(defined, Definition{Name: tmpl.Name(), File: file, Calls: calls(tmpl.Tree.Root)})
+		}
+		sort.Slice(defined, func(i, j int) bool {
+			return defined[i].Name < defined[j].Name
+		})
+		definitions = append(definitions, defined...)
+	}
+	return definitions, nil
+}
+
+// calls returns the names of the templates a parse tree invokes.
+func calls(node parse.Node) []string {
+	var names []string
+	var walk func(parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.TemplateNode:
+			names = append(names, n.Name)
+			walk(n.Pipe)
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) > 1 {
+				function, isIdentifier := n.Args[0].(*parse.IdentifierNode)
+				name, isString := n.Args[1].(*parse.StringNode)
+				if isIdentifier && isString && (function.Ident == "partial" || function.Ident == "partialCached") {
+					names = append(names, name.Text)
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		}
+	}
+	walk(node)
+	return names
+}